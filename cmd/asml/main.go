@@ -19,6 +19,8 @@ var (
 	showState    bool
 	printMem     bool
 	printLegacy  bool
+	strict       bool
+	nonblocking  bool
 	compile      bool
 	printVersion bool
 
@@ -33,6 +35,9 @@ func init() {
 	flag.BoolVar(&showState, "state", false, "Write state every cycle")
 	flag.BoolVar(&printMem, "printmem", false, "Print the initial memory layout and exit")
 	flag.BoolVar(&compile, "compile", false, "Compile file to ASML program")
+	flag.BoolVar(&strict, "strict", false, "Fault on register width mismatches")
+	flag.BoolVar(&printLegacy, "legacyprinter", false, "Write printer output in a block when the machine halts")
+	flag.BoolVar(&nonblocking, "nonblocking", false, "Don't wait for console input when it's read")
 	flag.BoolVar(&printVersion, "version", false, "Print version information")
 }
 
@@ -61,9 +66,12 @@ func main() {
 		return
 	}
 
-	sim := vm.New(code, showState)
-
 	if printMem {
+		sim, err := vm.New(code)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
 		sim.PrintState()
 		os.Stdout.Write(sim.Output())
 		os.Stdout.Write([]byte{'\n'})
@@ -83,8 +91,22 @@ func main() {
 		defer file.Close()
 	}
 
-	if err := sim.Run(output); err != nil {
+	sim, err := vm.New(code,
+		vm.WithOutput(output),
+		vm.WithPrintState(showState),
+		vm.WithStrict(strict),
+		vm.WithLegacyPrinter(printLegacy),
+		vm.WithDevice(vm.NewInput(os.Stdin, !nonblocking)),
+		vm.WithDevice(vm.NewTimer()),
+	)
+	if err != nil {
 		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	if err := sim.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 }
 
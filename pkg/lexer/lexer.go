@@ -72,6 +72,12 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.NewSimpleToken(token.IMMEDIATE, l.line, l.column)
 	case ',':
 		tok = token.NewSimpleToken(token.COMMA, l.line, l.column)
+	case '+':
+		tok = token.NewSimpleToken(token.PLUS, l.line, l.column)
+	case '[':
+		tok = token.NewSimpleToken(token.LBRACKET, l.line, l.column)
+	case ']':
+		tok = token.NewSimpleToken(token.RBRACKET, l.line, l.column)
 	case '"':
 		tok = token.NewToken(token.STRING, l.readString(), l.line, l.column)
 	case ';':
@@ -95,7 +101,7 @@ func (l *Lexer) NextToken() token.Token {
 			tokType := token.LookupIdent(lit)
 			tok = token.NewToken(tokType, lit, l.line, l.column)
 			return tok
-		} else if isDigit(l.curCh) || l.curCh == '!' {
+		} else if isDigit(l.curCh) || l.curCh == '!' || (l.curCh == '-' && isDigit(l.peekCh)) {
 			tok = l.readNumber()
 			return tok
 		}
@@ -137,6 +143,11 @@ func (l *Lexer) readString() string {
 func (l *Lexer) readNumber() token.Token {
 	var ident bytes.Buffer
 
+	if l.curCh == '-' {
+		ident.WriteByte(l.curCh)
+		l.readChar()
+	}
+
 	for isDigit(l.curCh) || isHexDigit(l.curCh) || l.curCh == '!' {
 		ident.WriteByte(l.curCh)
 		l.readChar()
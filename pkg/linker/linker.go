@@ -16,8 +16,21 @@ func Link(program *parser.Program) error {
 
 			newloc := memloc + uint16(label.Offset)
 
-			part.Bytes[loc] = uint8(newloc >> 8)
-			part.Bytes[loc+1] = uint8(newloc)
+			switch label.Kind {
+			case parser.LinkRel8:
+				disp := newloc - label.Base
+				if !parser.FitsRel8(disp) {
+					return fmt.Errorf("branch to label %s out of range", label.Label)
+				}
+				part.Bytes[loc] = uint8(disp)
+			case parser.LinkRel16:
+				disp := newloc - label.Base
+				part.Bytes[loc] = uint8(disp >> 8)
+				part.Bytes[loc+1] = uint8(disp)
+			default:
+				part.Bytes[loc] = uint8(newloc >> 8)
+				part.Bytes[loc+1] = uint8(newloc)
+			}
 		}
 	}
 
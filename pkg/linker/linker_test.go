@@ -0,0 +1,50 @@
+package linker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lfkeitel/asml-sim/pkg/lexer"
+	"github.com/lfkeitel/asml-sim/pkg/parser"
+)
+
+func TestLinkRelativeRange(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		at   int    // Address of the branch instruction
+		disp []byte // Expected displacement bytes
+		err  bool
+	}{
+		{"forward 127", "BRA far\nRMB 127\n:far\nHALT", 0, []byte{0x7F}, false},
+		{"forward 128", "BRA far\nRMB 128\n:far\nHALT", 0, nil, true},
+		{"backward 128", ":back\nRMB 126\nBRA back", 126, []byte{0x80}, false},
+		{"backward 129", ":back\nRMB 127\nBRA back", 127, nil, true},
+		{"conditional forward 128", "BZ far\nRMB 128\n:far\nHALT", 0, nil, true},
+		{"long forward 128", "LBRA far\nRMB 128\n:far\nHALT", 0, []byte{0x00, 0x80}, false},
+	}
+
+	for _, test := range tests {
+		program, err := parser.New(lexer.NewString(test.src + "\n")).Parse()
+		if err != nil {
+			t.Fatalf("%s: parsing failed: %v", test.name, err)
+		}
+
+		err = Link(program)
+		if test.err {
+			if err == nil || !strings.Contains(err.Error(), "out of range") {
+				t.Errorf("%s: expected out of range error, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		code := program.Parts[0].Bytes
+		if got := code[test.at+1 : test.at+1+len(test.disp)]; string(got) != string(test.disp) {
+			t.Errorf("%s: got displacement % X, expected % X", test.name, got, test.disp)
+		}
+	}
+}
@@ -1,6 +1,14 @@
 package opcodes
 
-// Language opcodes
+// IndexPostInc is set in the index register byte of an indexed mode
+// instruction to increment the index register after the access.
+const IndexPostInc byte = 0x80
+
+// RegSP is the register number used to encode the stack pointer as an operand.
+const RegSP = 0x0E
+
+// Language opcodes. New opcodes are added at the end so the values of
+// existing opcodes don't change.
 const (
 	NOOP byte = iota
 
@@ -47,4 +55,288 @@ const (
 
 	POP
 	PUSH
+
+	JZ
+	JNZ
+	JC
+	JNC
+	JN
+	JNN
+	JV
+	JNV
+
+	SUBA
+	SUBI
+	SUBR
+
+	CMPA
+	CMPI
+	CMPR
+
+	NEGA
+	NEGI
+	NEGR
+
+	MULA
+	MULI
+	MULR
+
+	DIVA
+	DIVI
+	DIVR
+
+	MODA
+	MODI
+	MODR
+
+	SHLI
+	SHLR
+	SHRI
+	SHRR
+	ASRI
+	ASRR
+
+	ADDX
+	ANDX
+	ORX
+	XORX
+	LOADX
+	STRX
+
+	LOADP
+	STRP
+	JMPP
+	CALLP
+
+	BRA
+	BSR
+	LBRA
+	LBSR
+
+	BZ
+	BNZ
+	BC
+	BNC
+	BN
+	BNN
+	BV
+	BNV
+
+	LOADSP
+	STRSP
+
+	INC
+	DEC
+	LOOP
+
+	ADCA
+	ADCI
+	ADCR
+
+	SBCA
+	SBCI
+	SBCR
+
+	BSETA
+	BSETR
+	BCLRA
+	BCLRR
+	BTSTA
+	BTSTR
+
+	MEMCPY
+	MEMSET
+
+	LOADSA
+	LOADSR
+	LOADZA
+	LOADZR
+
+	XFERS
+	XFERZ
+	TRUNC
+
+	POPA
+	PUSHA
+	POPF
+	PUSHF
+
+	CALLEQ
+
+	CALLZ
+	CALLNZ
+	CALLC
+	CALLNC
+	CALLN
+	CALLNN
+	CALLV
+	CALLNV
+
+	RTNZ
+	RTNNZ
+	RTNC
+	RTNNC
+	RTNN
+	RTNNN
+	RTNV
+	RTNNV
+
+	EI
+	DI
+	RTI
+	WAI
+
+	MULW
+	DIVW
+	MODW
 )
+
+// names maps opcodes to their mnemonic
+var names = map[byte]string{
+	NOOP:   "NOOP",
+	ADDA:   "ADDA",
+	ADDI:   "ADDI",
+	ADDR:   "ADDR",
+	ANDA:   "ANDA",
+	ANDI:   "ANDI",
+	ANDR:   "ANDR",
+	ORA:    "ORA",
+	ORI:    "ORI",
+	ORR:    "ORR",
+	XORA:   "XORA",
+	XORI:   "XORI",
+	XORR:   "XORR",
+	ROTR:   "ROTR",
+	ROTL:   "ROTL",
+	CALLA:  "CALLA",
+	CALLR:  "CALLR",
+	RTN:    "RTN",
+	HALT:   "HALT",
+	JMP:    "JMP",
+	JMPA:   "JMPA",
+	LDSPA:  "LDSPA",
+	LDSPI:  "LDSPI",
+	LDSPR:  "LDSPR",
+	LOADA:  "LOADA",
+	LOADI:  "LOADI",
+	LOADR:  "LOADR",
+	STRA:   "STRA",
+	STRR:   "STRR",
+	XFER:   "XFER",
+	POP:    "POP",
+	PUSH:   "PUSH",
+	JZ:     "JZ",
+	JNZ:    "JNZ",
+	JC:     "JC",
+	JNC:    "JNC",
+	JN:     "JN",
+	JNN:    "JNN",
+	JV:     "JV",
+	JNV:    "JNV",
+	SUBA:   "SUBA",
+	SUBI:   "SUBI",
+	SUBR:   "SUBR",
+	CMPA:   "CMPA",
+	CMPI:   "CMPI",
+	CMPR:   "CMPR",
+	NEGA:   "NEGA",
+	NEGI:   "NEGI",
+	NEGR:   "NEGR",
+	MULA:   "MULA",
+	MULI:   "MULI",
+	MULR:   "MULR",
+	DIVA:   "DIVA",
+	DIVI:   "DIVI",
+	DIVR:   "DIVR",
+	MODA:   "MODA",
+	MODI:   "MODI",
+	MODR:   "MODR",
+	SHLI:   "SHLI",
+	SHLR:   "SHLR",
+	SHRI:   "SHRI",
+	SHRR:   "SHRR",
+	ASRI:   "ASRI",
+	ASRR:   "ASRR",
+	ADDX:   "ADDX",
+	ANDX:   "ANDX",
+	ORX:    "ORX",
+	XORX:   "XORX",
+	LOADX:  "LOADX",
+	STRX:   "STRX",
+	LOADP:  "LOADP",
+	STRP:   "STRP",
+	JMPP:   "JMPP",
+	CALLP:  "CALLP",
+	BRA:    "BRA",
+	BSR:    "BSR",
+	LBRA:   "LBRA",
+	LBSR:   "LBSR",
+	BZ:     "BZ",
+	BNZ:    "BNZ",
+	BC:     "BC",
+	BNC:    "BNC",
+	BN:     "BN",
+	BNN:    "BNN",
+	BV:     "BV",
+	BNV:    "BNV",
+	LOADSP: "LOADSP",
+	STRSP:  "STRSP",
+	INC:    "INC",
+	DEC:    "DEC",
+	LOOP:   "LOOP",
+	ADCA:   "ADCA",
+	ADCI:   "ADCI",
+	ADCR:   "ADCR",
+	SBCA:   "SBCA",
+	SBCI:   "SBCI",
+	SBCR:   "SBCR",
+	BSETA:  "BSETA",
+	BSETR:  "BSETR",
+	BCLRA:  "BCLRA",
+	BCLRR:  "BCLRR",
+	BTSTA:  "BTSTA",
+	BTSTR:  "BTSTR",
+	MEMCPY: "MEMCPY",
+	MEMSET: "MEMSET",
+	LOADSA: "LOADSA",
+	LOADSR: "LOADSR",
+	LOADZA: "LOADZA",
+	LOADZR: "LOADZR",
+	XFERS:  "XFERS",
+	XFERZ:  "XFERZ",
+	TRUNC:  "TRUNC",
+	POPA:   "POPA",
+	PUSHA:  "PUSHA",
+	POPF:   "POPF",
+	PUSHF:  "PUSHF",
+	CALLEQ: "CALLEQ",
+	CALLZ:  "CALLZ",
+	CALLNZ: "CALLNZ",
+	CALLC:  "CALLC",
+	CALLNC: "CALLNC",
+	CALLN:  "CALLN",
+	CALLNN: "CALLNN",
+	CALLV:  "CALLV",
+	CALLNV: "CALLNV",
+	RTNZ:   "RTNZ",
+	RTNNZ:  "RTNNZ",
+	RTNC:   "RTNC",
+	RTNNC:  "RTNNC",
+	RTNN:   "RTNN",
+	RTNNN:  "RTNNN",
+	RTNV:   "RTNV",
+	RTNNV:  "RTNNV",
+	EI:     "EI",
+	DI:     "DI",
+	RTI:    "RTI",
+	WAI:    "WAI",
+	MULW:   "MULW",
+	DIVW:   "DIVW",
+	MODW:   "MODW",
+}
+
+// Name returns the mnemonic of an opcode and whether the opcode is valid.
+func Name(op byte) (string, bool) {
+	name, ok := names[op]
+	return name, ok
+}
@@ -1,17 +1,44 @@
 package parser
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/lfkeitel/asml-sim/pkg/opcodes"
 	"github.com/lfkeitel/asml-sim/pkg/token"
 )
 
+// parseRegisterLiteral returns the number of a register. Only the SP
+// literal gives the stack pointer, numbers above %D are invalid.
 func parseRegisterLiteral(s string) (uint8, error) {
+	if s == "SP" {
+		return opcodes.RegSP, nil
+	}
+
 	reg, err := strconv.ParseUint(s, 16, 8)
+	if err == nil && reg > 13 {
+		err = fmt.Errorf("invalid register %s", s)
+	}
 	return uint8(reg), err
 }
 
+// regWidth returns the width in bytes of a register number.
+func regWidth(reg uint8) uint8 {
+	if reg >= 0xA {
+		return 2
+	}
+	return 1
+}
+
+// regName returns the source name of a register number.
+func regName(reg uint8) string {
+	if reg == opcodes.RegSP {
+		return "SP"
+	}
+	return fmt.Sprintf("%X", reg)
+}
+
 func parseUint16(s string) (uint16, error) {
 	var (
 		val uint64
@@ -20,6 +47,10 @@ func parseUint16(s string) (uint16, error) {
 
 	if s[0] == '!' {
 		val, err = strconv.ParseUint(s[1:], 2, 16)
+	} else if s[0] == '-' {
+		var sval int64
+		sval, err = strconv.ParseInt(s, 0, 16)
+		val = uint64(uint16(sval))
 	} else {
 		val, err = strconv.ParseUint(s, 0, 16)
 	}
@@ -36,7 +67,7 @@ func (p *Parser) parseRegister() (uint8, bool) {
 	regT := p.ct
 
 	reg, err := parseRegisterLiteral(regT.Literal)
-	if err != nil || reg > 13 {
+	if err != nil {
 		p.parseErr("invalid register")
 		return 0, false
 	}
@@ -44,6 +75,30 @@ func (p *Parser) parseRegister() (uint8, bool) {
 	return reg, true
 }
 
+// checkRegWidth sets a parse error if the register isn't w bytes wide.
+func (p *Parser) checkRegWidth(reg, w uint8) bool {
+	if regWidth(reg) != w {
+		p.parseErr(fmt.Sprintf("register %%%s must be %d bits wide", p.ct.Literal, w*8))
+		return false
+	}
+	return true
+}
+
+// peekRegWidth sets a parse error if the next token is a register that
+// isn't w bytes wide.
+func (p *Parser) peekRegWidth(w uint8) bool {
+	if !p.peekTokenIs(token.REGISTER) {
+		return true
+	}
+
+	reg, err := parseRegisterLiteral(p.peek.Literal)
+	if err == nil && regWidth(reg) != w {
+		p.parseErr(fmt.Sprintf("register %%%s must be %d bits wide", p.peek.Literal, w*8))
+		return false
+	}
+	return true
+}
+
 func (p *Parser) parseUint16() (uint16, bool) {
 	if !p.curTokenIs(token.NUMBER) {
 		p.tokenErr(token.NUMBER)
@@ -74,29 +129,9 @@ func (p *Parser) parseAddress(pcoffset uint16) (uint16, bool) {
 			return 0, false
 		}
 	} else if p.curTokenIs(token.IDENT) {
-		label := p.ct.Literal
-		lit := label
-		var offset uint16
-
-		addIndex := strings.Index(lit, "+")
-		subIndex := strings.Index(lit, "-")
-
-		if addIndex > 0 || subIndex > 0 {
-			ind := addIndex
-			if subIndex > 0 {
-				ind = subIndex
-			}
-			label = lit[:ind]
-			offset64, err := strconv.ParseInt(string(lit[ind+1:]), 0, 16)
-			if err != nil {
-				p.parseErr("invalid address offset")
-				return 0, false
-			}
-
-			offset = uint16(offset64)
-			if subIndex > 0 {
-				offset = -offset
-			}
+		label, offset, ok := p.parseLabelOffset()
+		if !ok {
+			return 0, false
 		}
 
 		if label == "$" {
@@ -125,3 +160,77 @@ func (p *Parser) parseAddress(pcoffset uint16) (uint16, bool) {
 
 	return val, true
 }
+
+// parseLabelOffset splits the current identifier into a label name and the
+// offset added to or subtracted from it.
+func (p *Parser) parseLabelOffset() (string, uint16, bool) {
+	label := p.ct.Literal
+	lit := label
+	var offset uint16
+
+	addIndex := strings.Index(lit, "+")
+	subIndex := strings.Index(lit, "-")
+
+	if addIndex > 0 || subIndex > 0 {
+		ind := addIndex
+		if subIndex > 0 {
+			ind = subIndex
+		}
+		label = lit[:ind]
+		offset64, err := strconv.ParseInt(string(lit[ind+1:]), 0, 16)
+		if err != nil {
+			p.parseErr("invalid address offset")
+			return "", 0, false
+		}
+
+		offset = uint16(offset64)
+		if subIndex > 0 {
+			offset = -offset
+		}
+	}
+
+	return label, offset, true
+}
+
+// parseDisplacement parses a branch target and returns its displacement from
+// base. Displacements to labels are filled in by the linker.
+func (p *Parser) parseDisplacement(pcoffset, base uint16, kind LinkKind) (uint16, bool) {
+	var target uint16
+
+	if p.curTokenIs(token.NUMBER) {
+		val, err := parseUint16(p.ct.Literal)
+		if err != nil {
+			p.parseErr("invalid address")
+			return 0, false
+		}
+		target = val
+	} else if p.curTokenIs(token.IDENT) {
+		label, offset, ok := p.parseLabelOffset()
+		if !ok {
+			return 0, false
+		}
+
+		if label != "$" {
+			p.p.addRelLink(pcoffset, label, int16(offset), kind, base)
+			return 0, true
+		}
+		target = p.p.pc() + offset
+	} else {
+		p.tokenErr(token.LABEL, token.NUMBER)
+		return 0, false
+	}
+
+	disp := target - base
+	if kind == LinkRel8 && !FitsRel8(disp) {
+		p.parseErr("branch target out of range")
+		return 0, false
+	}
+
+	return disp, true
+}
+
+// FitsRel8 returns if a displacement fits in a signed 8-bit value.
+func FitsRel8(disp uint16) bool {
+	d := int16(disp)
+	return d >= -128 && d <= 127
+}
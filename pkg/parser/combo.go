@@ -1,6 +1,61 @@
 package parser
 
-import "github.com/lfkeitel/asml-sim/pkg/token"
+import (
+	"fmt"
+
+	"github.com/lfkeitel/asml-sim/pkg/opcodes"
+	"github.com/lfkeitel/asml-sim/pkg/token"
+)
+
+type widthRule int
+
+const (
+	widthAny   widthRule = iota // Source register is a count or address
+	widthMatch                  // Source and destination must be the same width
+	widthWiden                  // Source may be narrower than the destination
+)
+
+// regWidthRules maps a register mode opcode to the width rule its
+// destination and source registers must follow.
+var regWidthRules = map[byte]widthRule{
+	opcodes.XFER: widthMatch,
+	opcodes.ADDR: widthMatch,
+	opcodes.ADCR: widthMatch,
+	opcodes.SUBR: widthMatch,
+	opcodes.SBCR: widthMatch,
+	opcodes.CMPR: widthMatch,
+	opcodes.NEGR: widthMatch,
+	opcodes.ANDR: widthMatch,
+	opcodes.ORR:  widthMatch,
+	opcodes.XORR: widthMatch,
+	opcodes.MULR: widthWiden,
+	opcodes.DIVR: widthWiden,
+	opcodes.MODR: widthWiden,
+}
+
+// indexedModes maps an address mode opcode to its indexed mode counterpart.
+var indexedModes = map[byte]byte{
+	opcodes.LOADA: opcodes.LOADX,
+	opcodes.STRA:  opcodes.STRX,
+	opcodes.ADDA:  opcodes.ADDX,
+	opcodes.ANDA:  opcodes.ANDX,
+	opcodes.ORA:   opcodes.ORX,
+	opcodes.XORA:  opcodes.XORX,
+}
+
+// stackModes maps an address mode opcode to its stack pointer relative counterpart.
+var stackModes = map[byte]byte{
+	opcodes.LOADA: opcodes.LOADSP,
+	opcodes.STRA:  opcodes.STRSP,
+}
+
+// indirectModes maps an address mode opcode to its memory indirect counterpart.
+var indirectModes = map[byte]byte{
+	opcodes.LOADA: opcodes.LOADP,
+	opcodes.STRA:  opcodes.STRP,
+	opcodes.JMPA:  opcodes.JMPP,
+	opcodes.CALLA: opcodes.CALLP,
+}
 
 func (p *Parser) parseInst(imm, addr, reg byte) {
 	p.readToken()
@@ -16,7 +71,13 @@ func (p *Parser) parseInst(imm, addr, reg byte) {
 			return
 		}
 
-		p.p.appendCode(addr, dest, uint8(val>>8), uint8(val))
+		if p.peekTokenIs(token.COMMA) {
+			if !p.parseIndexed(addr, dest, val) {
+				return
+			}
+		} else {
+			p.p.appendCode(addr, dest, uint8(val>>8), uint8(val))
+		}
 	} else if p.curTokenIs(token.IMMEDIATE) {
 		p.readToken()
 		val, ok := p.parseAddress(2)
@@ -25,15 +86,22 @@ func (p *Parser) parseInst(imm, addr, reg byte) {
 		}
 
 		p.p.appendCode(imm, dest, uint8(val>>8), uint8(val))
+	} else if p.curTokenIs(token.LBRACKET) {
+		op, val, ok := p.parseIndirect(addr, 2)
+		if !ok {
+			return
+		}
+
+		p.p.appendCode(op, dest, uint8(val>>8), uint8(val))
 	} else if p.curTokenIs(token.REGISTER) {
 		src, ok := p.parseRegister()
-		if !ok {
+		if !ok || !p.checkRegPair(reg, dest, src) {
 			return
 		}
 
 		p.p.appendCode(reg, dest, src)
 	} else {
-		p.tokenErr(token.NUMBER, token.IMMEDIATE, token.REGISTER)
+		p.tokenErr(token.NUMBER, token.IMMEDIATE, token.LBRACKET, token.REGISTER)
 	}
 	p.expectToken(token.END_INST)
 }
@@ -52,16 +120,29 @@ func (p *Parser) parseInstNoImm(addr, reg byte) {
 			return
 		}
 
-		p.p.appendCode(addr, dest, uint8(val>>8), uint8(val))
+		if p.peekTokenIs(token.COMMA) {
+			if !p.parseIndexed(addr, dest, val) {
+				return
+			}
+		} else {
+			p.p.appendCode(addr, dest, uint8(val>>8), uint8(val))
+		}
+	} else if p.curTokenIs(token.LBRACKET) {
+		op, val, ok := p.parseIndirect(addr, 2)
+		if !ok {
+			return
+		}
+
+		p.p.appendCode(op, dest, uint8(val>>8), uint8(val))
 	} else if p.curTokenIs(token.REGISTER) {
 		src, ok := p.parseRegister()
-		if !ok {
+		if !ok || !p.checkRegPair(reg, dest, src) {
 			return
 		}
 
 		p.p.appendCode(reg, dest, src)
 	} else {
-		p.tokenErr(token.NUMBER, token.REGISTER)
+		p.tokenErr(token.NUMBER, token.LBRACKET, token.REGISTER)
 	}
 	p.expectToken(token.END_INST)
 }
@@ -105,6 +186,13 @@ func (p *Parser) parseInstNoImmNoDest(addr, reg byte) {
 		}
 
 		p.p.appendCode(addr, uint8(val>>8), uint8(val))
+	} else if p.curTokenIs(token.LBRACKET) {
+		op, val, ok := p.parseIndirect(addr, 1)
+		if !ok {
+			return
+		}
+
+		p.p.appendCode(op, uint8(val>>8), uint8(val))
 	} else if p.curTokenIs(token.REGISTER) {
 		src, ok := p.parseRegister()
 		if !ok {
@@ -113,7 +201,93 @@ func (p *Parser) parseInstNoImmNoDest(addr, reg byte) {
 
 		p.p.appendCode(reg, src)
 	} else {
-		p.tokenErr(token.NUMBER, token.REGISTER)
+		p.tokenErr(token.NUMBER, token.LBRACKET, token.REGISTER)
 	}
 	p.expectToken(token.END_INST)
 }
+
+// parseIndexed parses the ",%r" or ",%r+" index register suffix of an indexed
+// address and writes the indexed mode form of addr. An index register of %SP
+// uses the stack pointer relative form instead.
+func (p *Parser) parseIndexed(addr, dest byte, base uint16) bool {
+	p.readToken() // Comma
+	p.readToken()
+
+	if p.curTokenIs(token.REGISTER) && p.ct.Literal == "SP" {
+		op, ok := stackModes[addr]
+		if !ok {
+			p.parseErr("stack pointer relative addressing not supported")
+			return false
+		}
+		if p.peekTokenIs(token.PLUS) {
+			p.parseErr("stack pointer can't be post incremented")
+			return false
+		}
+
+		p.p.appendCode(op, dest, uint8(base>>8), uint8(base))
+		return true
+	}
+
+	op, ok := indexedModes[addr]
+	if !ok {
+		p.parseErr("indexed addressing not supported")
+		return false
+	}
+
+	reg, ok := p.parseRegister()
+	if !ok {
+		return false
+	}
+
+	if p.peekTokenIs(token.PLUS) {
+		p.readToken()
+		reg |= opcodes.IndexPostInc
+	}
+
+	p.p.appendCode(op, dest, uint8(base>>8), uint8(base), reg)
+	return true
+}
+
+// parseIndirect parses a "[ptr]" memory indirect operand. It returns the
+// indirect mode opcode for addr and the address of the pointer.
+func (p *Parser) parseIndirect(addr byte, pcoffset uint16) (byte, uint16, bool) {
+	op, ok := indirectModes[addr]
+	if !ok {
+		p.parseErr("indirect addressing not supported")
+		return 0, 0, false
+	}
+
+	p.readToken() // Left bracket
+	val, ok := p.parseAddress(pcoffset)
+	if !ok {
+		return 0, 0, false
+	}
+
+	p.readToken()
+	if !p.curTokenIs(token.RBRACKET) {
+		p.tokenErr(token.RBRACKET)
+		return 0, 0, false
+	}
+
+	return op, val, true
+}
+
+// checkRegPair sets a parse error if the destination and source registers
+// of a register mode instruction don't follow its width rule.
+func (p *Parser) checkRegPair(op, dest, src byte) bool {
+	dw, sw := regWidth(dest), regWidth(src)
+
+	ok := true
+	switch regWidthRules[op] {
+	case widthMatch:
+		ok = dw == sw
+	case widthWiden:
+		ok = sw <= dw
+	}
+
+	if !ok {
+		p.parseErr(fmt.Sprintf("register width mismatch, %%%s is %d bits and %%%s is %d bits",
+			regName(dest), dw*8, regName(src), sw*8))
+	}
+	return ok
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/lfkeitel/asml-sim/pkg/opcodes"
 	"github.com/lfkeitel/asml-sim/pkg/token"
 )
@@ -11,7 +13,42 @@ func (p *Parser) insStore() { p.parseInstNoImm(opcodes.STRA, opcodes.STRR) }
 
 func (p *Parser) insMovr() { p.parseRegReg(opcodes.XFER) }
 
+func (p *Parser) insLoads() {
+	if p.peekRegWidth(2) {
+		p.parseInstNoImm(opcodes.LOADSA, opcodes.LOADSR)
+	}
+}
+
+func (p *Parser) insLoadz() {
+	if p.peekRegWidth(2) {
+		p.parseInstNoImm(opcodes.LOADZA, opcodes.LOADZR)
+	}
+}
+
+func (p *Parser) insXfers() { p.parseRegsOfWidth(opcodes.XFERS, 2, 1) }
+func (p *Parser) insXferz() { p.parseRegsOfWidth(opcodes.XFERZ, 2, 1) }
+func (p *Parser) insTrunc() { p.parseRegsOfWidth(opcodes.TRUNC, 1, 2) }
+
+func (p *Parser) insMemcpy() { p.parseRegsOfWidth(opcodes.MEMCPY, 2, 2, 2) }
+func (p *Parser) insMemset() { p.parseRegsOfWidth(opcodes.MEMSET, 2, 1, 2) }
+
 func (p *Parser) insAdd() { p.parseInst(opcodes.ADDI, opcodes.ADDA, opcodes.ADDR) }
+func (p *Parser) insAdc() { p.parseInst(opcodes.ADCI, opcodes.ADCA, opcodes.ADCR) }
+func (p *Parser) insSub() { p.parseInst(opcodes.SUBI, opcodes.SUBA, opcodes.SUBR) }
+func (p *Parser) insSbc() { p.parseInst(opcodes.SBCI, opcodes.SBCA, opcodes.SBCR) }
+func (p *Parser) insCmp() { p.parseInst(opcodes.CMPI, opcodes.CMPA, opcodes.CMPR) }
+func (p *Parser) insNeg() { p.parseInst(opcodes.NEGI, opcodes.NEGA, opcodes.NEGR) }
+
+func (p *Parser) insInc() { p.parseReg(opcodes.INC) }
+func (p *Parser) insDec() { p.parseReg(opcodes.DEC) }
+
+func (p *Parser) insMul() { p.parseInst(opcodes.MULI, opcodes.MULA, opcodes.MULR) }
+func (p *Parser) insDiv() { p.parseInst(opcodes.DIVI, opcodes.DIVA, opcodes.DIVR) }
+func (p *Parser) insMod() { p.parseInst(opcodes.MODI, opcodes.MODA, opcodes.MODR) }
+
+func (p *Parser) insMulw() { p.parseRegsOfWidth(opcodes.MULW, 2, 1, 1) }
+func (p *Parser) insDivw() { p.parseRegsOfWidth(opcodes.DIVW, 1, 2, 1) }
+func (p *Parser) insModw() { p.parseRegsOfWidth(opcodes.MODW, 1, 2, 1) }
 
 func (p *Parser) insOr()  { p.parseInst(opcodes.ORI, opcodes.ORA, opcodes.ORR) }
 func (p *Parser) insAnd() { p.parseInst(opcodes.ANDI, opcodes.ANDA, opcodes.ANDR) }
@@ -20,20 +57,101 @@ func (p *Parser) insXor() { p.parseInst(opcodes.XORI, opcodes.XORA, opcodes.XORR
 func (p *Parser) insRotr() { p.parseRegHalfNumber(opcodes.ROTR) }
 func (p *Parser) insRotl() { p.parseRegHalfNumber(opcodes.ROTL) }
 
+func (p *Parser) insShl() { p.parseRegHalfNumberOrReg(opcodes.SHLI, opcodes.SHLR) }
+func (p *Parser) insShr() { p.parseRegHalfNumberOrReg(opcodes.SHRI, opcodes.SHRR) }
+func (p *Parser) insAsr() { p.parseRegHalfNumberOrReg(opcodes.ASRI, opcodes.ASRR) }
+
+func (p *Parser) insBset() { p.parseBit(opcodes.BSETA, opcodes.BSETR) }
+func (p *Parser) insBclr() { p.parseBit(opcodes.BCLRA, opcodes.BCLRR) }
+func (p *Parser) insBtst() { p.parseBit(opcodes.BTSTA, opcodes.BTSTR) }
+
 func (p *Parser) insJmp()  { p.parseRegNumber(opcodes.JMP) }
 func (p *Parser) insJmpa() { p.parseNumber(opcodes.JMPA) }
+func (p *Parser) insLoop() { p.parseRegNumber(opcodes.LOOP) }
+
+func (p *Parser) insJz()  { p.parseNumber(opcodes.JZ) }
+func (p *Parser) insJnz() { p.parseNumber(opcodes.JNZ) }
+func (p *Parser) insJc()  { p.parseNumber(opcodes.JC) }
+func (p *Parser) insJnc() { p.parseNumber(opcodes.JNC) }
+func (p *Parser) insJn()  { p.parseNumber(opcodes.JN) }
+func (p *Parser) insJnn() { p.parseNumber(opcodes.JNN) }
+func (p *Parser) insJv()  { p.parseNumber(opcodes.JV) }
+func (p *Parser) insJnv() { p.parseNumber(opcodes.JNV) }
+
+func (p *Parser) insBra()  { p.parseBranch(opcodes.BRA, LinkRel8) }
+func (p *Parser) insBsr()  { p.parseBranch(opcodes.BSR, LinkRel8) }
+func (p *Parser) insLbra() { p.parseBranch(opcodes.LBRA, LinkRel16) }
+func (p *Parser) insLbsr() { p.parseBranch(opcodes.LBSR, LinkRel16) }
+
+func (p *Parser) insBz()  { p.parseBranch(opcodes.BZ, LinkRel8) }
+func (p *Parser) insBnz() { p.parseBranch(opcodes.BNZ, LinkRel8) }
+func (p *Parser) insBc()  { p.parseBranch(opcodes.BC, LinkRel8) }
+func (p *Parser) insBnc() { p.parseBranch(opcodes.BNC, LinkRel8) }
+func (p *Parser) insBn()  { p.parseBranch(opcodes.BN, LinkRel8) }
+func (p *Parser) insBnn() { p.parseBranch(opcodes.BNN, LinkRel8) }
+func (p *Parser) insBv()  { p.parseBranch(opcodes.BV, LinkRel8) }
+func (p *Parser) insBnv() { p.parseBranch(opcodes.BNV, LinkRel8) }
 
 func (p *Parser) insLdsp() { p.parseInstNoDest(opcodes.LDSPI, opcodes.LDSPA, opcodes.LDSPR) }
 
 func (p *Parser) insPush() { p.parseReg(opcodes.PUSH) }
 func (p *Parser) insPop()  { p.parseReg(opcodes.POP) }
 
-func (p *Parser) insCall() { p.parseInstNoImmNoDest(opcodes.CALLA, opcodes.CALLR) }
+func (p *Parser) insPusha() { p.parseNoArgs(opcodes.PUSHA) }
+func (p *Parser) insPopa()  { p.parseNoArgs(opcodes.POPA) }
+func (p *Parser) insPushf() { p.parseNoArgs(opcodes.PUSHF) }
+func (p *Parser) insPopf()  { p.parseNoArgs(opcodes.POPF) }
+
+// insCall parses CALL. A register followed by an address is a conditional
+// call, made if the register equals register 0 like JMP.
+func (p *Parser) insCall() {
+	if !p.peekTokenIs(token.REGISTER) {
+		p.parseInstNoImmNoDest(opcodes.CALLA, opcodes.CALLR)
+		return
+	}
+
+	p.readToken()
+	reg, ok := p.parseRegister()
+	if !ok {
+		return
+	}
+
+	if p.peekTokenIs(token.END_INST) {
+		p.p.appendCode(opcodes.CALLR, reg)
+		p.expectToken(token.END_INST)
+		return
+	}
+
+	p.parseRegNumberArg(opcodes.CALLEQ, reg)
+}
+
+func (p *Parser) insCallz()  { p.parseNumber(opcodes.CALLZ) }
+func (p *Parser) insCallnz() { p.parseNumber(opcodes.CALLNZ) }
+func (p *Parser) insCallc()  { p.parseNumber(opcodes.CALLC) }
+func (p *Parser) insCallnc() { p.parseNumber(opcodes.CALLNC) }
+func (p *Parser) insCalln()  { p.parseNumber(opcodes.CALLN) }
+func (p *Parser) insCallnn() { p.parseNumber(opcodes.CALLNN) }
+func (p *Parser) insCallv()  { p.parseNumber(opcodes.CALLV) }
+func (p *Parser) insCallnv() { p.parseNumber(opcodes.CALLNV) }
+
+func (p *Parser) insRtnz()  { p.parseNoArgs(opcodes.RTNZ) }
+func (p *Parser) insRtnnz() { p.parseNoArgs(opcodes.RTNNZ) }
+func (p *Parser) insRtnc()  { p.parseNoArgs(opcodes.RTNC) }
+func (p *Parser) insRtnnc() { p.parseNoArgs(opcodes.RTNNC) }
+func (p *Parser) insRtnn()  { p.parseNoArgs(opcodes.RTNN) }
+func (p *Parser) insRtnnn() { p.parseNoArgs(opcodes.RTNNN) }
+func (p *Parser) insRtnv()  { p.parseNoArgs(opcodes.RTNV) }
+func (p *Parser) insRtnnv() { p.parseNoArgs(opcodes.RTNNV) }
 
 func (p *Parser) insHalt() { p.parseNoArgs(opcodes.HALT) }
 func (p *Parser) insNoop() { p.parseNoArgs(opcodes.NOOP) }
 func (p *Parser) insRtn()  { p.parseNoArgs(opcodes.RTN) }
 
+func (p *Parser) insEi()  { p.parseNoArgs(opcodes.EI) }
+func (p *Parser) insDi()  { p.parseNoArgs(opcodes.DI) }
+func (p *Parser) insRti() { p.parseNoArgs(opcodes.RTI) }
+func (p *Parser) insWai() { p.parseNoArgs(opcodes.WAI) }
+
 func (p *Parser) insRmb() {
 	p.readToken()
 	if !p.curTokenIs(token.NUMBER) {
@@ -100,7 +218,7 @@ func (p *Parser) parseRegReg(c byte) {
 	// Arg 2
 	p.readToken()
 	reg2, ok := p.parseRegister()
-	if !ok {
+	if !ok || !p.checkRegPair(c, reg, reg2) {
 		return
 	}
 
@@ -110,6 +228,30 @@ func (p *Parser) parseRegReg(c byte) {
 	p.expectToken(token.END_INST)
 }
 
+// parseRegsOfWidth parses a register argument for each width in widths.
+// Each register must be the given width in bytes.
+func (p *Parser) parseRegsOfWidth(c byte, widths ...uint8) {
+	code := []byte{c}
+
+	for _, w := range widths {
+		p.readToken()
+		reg, ok := p.parseRegister()
+		if !ok {
+			return
+		}
+
+		if !p.checkRegWidth(reg, w) {
+			return
+		}
+		code = append(code, reg)
+	}
+
+	// Write code
+	p.p.appendCode(code...)
+
+	p.expectToken(token.END_INST)
+}
+
 func (p *Parser) parseRegNumber(c byte) {
 	// Arg 1
 	p.readToken()
@@ -118,6 +260,12 @@ func (p *Parser) parseRegNumber(c byte) {
 		return
 	}
 
+	p.parseRegNumberArg(c, reg)
+}
+
+// parseRegNumberArg parses the address argument of a register and
+// number instruction after the register has been parsed.
+func (p *Parser) parseRegNumberArg(c, reg byte) {
 	// Arg 2
 	p.readToken()
 	val, ok := p.parseAddress(2)
@@ -162,9 +310,108 @@ func (p *Parser) parseRegHalfNumber(c byte) {
 	p.expectToken(token.END_INST)
 }
 
+func (p *Parser) parseRegHalfNumberOrReg(imm, reg byte) {
+	// Arg 1
+	p.readToken()
+	dest, ok := p.parseRegister()
+	if !ok {
+		return
+	}
+
+	// Arg 2
+	p.readToken()
+	if p.curTokenIs(token.REGISTER) {
+		src, ok := p.parseRegister()
+		if !ok {
+			return
+		}
+
+		p.p.appendCode(reg, dest, src)
+		p.expectToken(token.END_INST)
+		return
+	}
+
+	if !p.curTokenIs(token.IMMEDIATE) {
+		p.tokenErr(token.IMMEDIATE, token.REGISTER)
+		return
+	}
+
+	p.readToken()
+	val, ok := p.parseAddress(2)
+	if !ok {
+		return
+	}
+
+	if val > 255 {
+		p.parseErr("shift count too large, must be 0-255")
+		return
+	}
+
+	// Write code
+	p.p.appendCode(imm, dest, uint8(val))
+
+	p.expectToken(token.END_INST)
+}
+
+func (p *Parser) parseBit(addr, reg byte) {
+	// Arg 1
+	p.readToken()
+	var code []byte
+	bits := uint16(8)
+
+	if p.curTokenIs(token.REGISTER) {
+		r, ok := p.parseRegister()
+		if !ok {
+			return
+		}
+		code = []byte{reg, r}
+		bits = uint16(regWidth(r)) * 8
+	} else {
+		val, ok := p.parseAddress(1)
+		if !ok {
+			return
+		}
+		code = []byte{addr, uint8(val >> 8), uint8(val)}
+	}
+
+	// Arg 2
+	p.readToken()
+	if !p.curTokenIs(token.IMMEDIATE) {
+		p.tokenErr(token.IMMEDIATE)
+		return
+	}
+
+	p.readToken()
+	val, ok := p.parseAddress(2)
+	if !ok {
+		return
+	}
+
+	if val >= bits {
+		p.parseErr(fmt.Sprintf("bit number too large, must be 0-%d", bits-1))
+		return
+	}
+
+	// Write code
+	p.p.appendCode(append(code, uint8(val))...)
+
+	p.expectToken(token.END_INST)
+}
+
 func (p *Parser) parseNumber(c byte) {
 	// Arg 1
 	p.readToken()
+	if p.curTokenIs(token.LBRACKET) {
+		op, val, ok := p.parseIndirect(c, 1)
+		if !ok {
+			return
+		}
+
+		p.p.appendCode(op, uint8(val>>8), uint8(val))
+		p.expectToken(token.END_INST)
+		return
+	}
+
 	val, ok := p.parseAddress(1)
 	if !ok {
 		return
@@ -175,3 +422,26 @@ func (p *Parser) parseNumber(c byte) {
 
 	p.expectToken(token.END_INST)
 }
+
+func (p *Parser) parseBranch(c byte, kind LinkKind) {
+	size := uint16(2)
+	if kind == LinkRel16 {
+		size = 3
+	}
+
+	// Arg 1
+	p.readToken()
+	disp, ok := p.parseDisplacement(1, p.p.pc()+size, kind)
+	if !ok {
+		return
+	}
+
+	// Write code
+	if kind == LinkRel16 {
+		p.p.appendCode(c, uint8(disp>>8), uint8(disp))
+	} else {
+		p.p.appendCode(c, uint8(disp))
+	}
+
+	p.expectToken(token.END_INST)
+}
@@ -42,14 +42,58 @@ func (p *Parser) Parse() (*Program, error) {
 		case token.LOAD:
 			p.insLoad()
 
+		case token.LOADS:
+			p.insLoads()
+		case token.LOADZ:
+			p.insLoadz()
+
 		case token.STR:
 			p.insStore()
 
 		case token.XFER:
 			p.insMovr()
+		case token.XFERS:
+			p.insXfers()
+		case token.XFERZ:
+			p.insXferz()
+		case token.TRUNC:
+			p.insTrunc()
+
+		case token.MEMCPY:
+			p.insMemcpy()
+		case token.MEMSET:
+			p.insMemset()
 
 		case token.ADD:
 			p.insAdd()
+		case token.ADC:
+			p.insAdc()
+		case token.SUB:
+			p.insSub()
+		case token.SBC:
+			p.insSbc()
+		case token.CMP:
+			p.insCmp()
+		case token.NEG:
+			p.insNeg()
+
+		case token.INC:
+			p.insInc()
+		case token.DEC:
+			p.insDec()
+
+		case token.MUL:
+			p.insMul()
+		case token.DIV:
+			p.insDiv()
+		case token.MOD:
+			p.insMod()
+		case token.MULW:
+			p.insMulw()
+		case token.DIVW:
+			p.insDivw()
+		case token.MODW:
+			p.insModw()
 
 		case token.OR:
 			p.insOr()
@@ -63,18 +107,101 @@ func (p *Parser) Parse() (*Program, error) {
 		case token.ROTL:
 			p.insRotl()
 
+		case token.SHL:
+			p.insShl()
+		case token.SHR:
+			p.insShr()
+		case token.ASR:
+			p.insAsr()
+
+		case token.BSET:
+			p.insBset()
+		case token.BCLR:
+			p.insBclr()
+		case token.BTST:
+			p.insBtst()
+
 		case token.PUSH:
 			p.insPush()
 		case token.POP:
 			p.insPop()
+		case token.PUSHA:
+			p.insPusha()
+		case token.POPA:
+			p.insPopa()
+		case token.PUSHF:
+			p.insPushf()
+		case token.POPF:
+			p.insPopf()
 
 		case token.CALL:
 			p.insCall()
+		case token.CALLZ:
+			p.insCallz()
+		case token.CALLNZ:
+			p.insCallnz()
+		case token.CALLC:
+			p.insCallc()
+		case token.CALLNC:
+			p.insCallnc()
+		case token.CALLN:
+			p.insCalln()
+		case token.CALLNN:
+			p.insCallnn()
+		case token.CALLV:
+			p.insCallv()
+		case token.CALLNV:
+			p.insCallnv()
 
 		case token.JMP:
 			p.insJmp()
 		case token.JMPA:
 			p.insJmpa()
+		case token.LOOP:
+			p.insLoop()
+
+		case token.JZ:
+			p.insJz()
+		case token.JNZ:
+			p.insJnz()
+		case token.JC:
+			p.insJc()
+		case token.JNC:
+			p.insJnc()
+		case token.JN:
+			p.insJn()
+		case token.JNN:
+			p.insJnn()
+		case token.JV:
+			p.insJv()
+		case token.JNV:
+			p.insJnv()
+
+		case token.BRA:
+			p.insBra()
+		case token.BSR:
+			p.insBsr()
+		case token.LBRA:
+			p.insLbra()
+		case token.LBSR:
+			p.insLbsr()
+
+		case token.BZ:
+			p.insBz()
+		case token.BNZ:
+			p.insBnz()
+		case token.BC:
+			p.insBc()
+		case token.BNC:
+			p.insBnc()
+		case token.BN:
+			p.insBn()
+		case token.BNN:
+			p.insBnn()
+		case token.BV:
+			p.insBv()
+		case token.BNV:
+			p.insBnv()
 
 		case token.LDSP:
 			p.insLdsp()
@@ -85,6 +212,31 @@ func (p *Parser) Parse() (*Program, error) {
 			p.insNoop()
 		case token.RTN:
 			p.insRtn()
+		case token.RTNZ:
+			p.insRtnz()
+		case token.RTNNZ:
+			p.insRtnnz()
+		case token.RTNC:
+			p.insRtnc()
+		case token.RTNNC:
+			p.insRtnnc()
+		case token.RTNN:
+			p.insRtnn()
+		case token.RTNNN:
+			p.insRtnnn()
+		case token.RTNV:
+			p.insRtnv()
+		case token.RTNNV:
+			p.insRtnnv()
+
+		case token.EI:
+			p.insEi()
+		case token.DI:
+			p.insDi()
+		case token.RTI:
+			p.insRti()
+		case token.WAI:
+			p.insWai()
 
 		case token.RMB:
 			p.insRmb()
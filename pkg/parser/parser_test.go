@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lfkeitel/asml-sim/pkg/lexer"
+)
+
+func TestParseRegister(t *testing.T) {
+	tests := []struct {
+		src  string
+		code []byte
+		err  string
+	}{
+		{"XFER %A %B", []byte{0x1D, 0x0A, 0x0B}, ""},
+		{"XFER %A %SP", []byte{0x1D, 0x0A, 0x0E}, ""},
+		{"XFER %A %D", []byte{0x1D, 0x0A, 0x0D}, ""},
+		{"XFER %A %E", nil, "invalid register"},
+		{"XFER %A %e", nil, "invalid register"},
+		{"XFER %A %F", nil, "invalid register"},
+		{"XFER %E %A", nil, "invalid register"},
+	}
+
+	for _, test := range tests {
+		program, err := New(lexer.NewString(test.src + "\n")).Parse()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, expected %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.src, err)
+			continue
+		}
+
+		code := program.Parts[0].Bytes
+		if !bytes.Equal(code, test.code) {
+			t.Errorf("%s: got % X, expected % X", test.src, code, test.code)
+		}
+	}
+}
@@ -5,9 +5,20 @@ import (
 	"sort"
 )
 
+// LinkKind is the kind of value the linker writes for a label reference.
+type LinkKind int
+
+const (
+	LinkAbs16 LinkKind = iota // Absolute 16-bit address
+	LinkRel8                  // Signed 8-bit displacement from Base
+	LinkRel16                 // Signed 16-bit displacement from Base
+)
+
 type LabelReplace struct {
 	Label  string
 	Offset int16
+	Kind   LinkKind
+	Base   uint16 // Address relative displacements are calculated from
 }
 
 type LabelMap map[string]uint16
@@ -55,6 +66,17 @@ func (p *Program) addLink(pcoffset uint16, name string, offset int16) {
 	}
 }
 
+func (p *Program) addRelLink(pcoffset uint16, name string, offset int16, kind LinkKind, base uint16) {
+	pc := p.Parts[p.partIndex].PC - p.Parts[p.partIndex].StartPC
+
+	p.Parts[p.partIndex].LinkMap[pc+pcoffset] = LabelReplace{
+		Label:  name,
+		Offset: offset,
+		Kind:   kind,
+		Base:   base,
+	}
+}
+
 func (p *Program) addCodePart(pc uint16) {
 	p.Parts = append(p.Parts, newCodePart(pc))
 	p.partIndex++
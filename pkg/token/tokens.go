@@ -17,6 +17,9 @@ const (
 	COMMENT
 	END_INST
 	COMMA
+	PLUS
+	LBRACKET
+	RBRACKET
 	IMMEDIATE
 
 	IDENT
@@ -28,22 +31,93 @@ const (
 	keyword_beg
 	NOOP
 	LOAD
+	LOADS
+	LOADZ
 	STR
 	XFER
+	XFERS
+	XFERZ
+	TRUNC
+	MEMCPY
+	MEMSET
 	ADD
+	ADC
+	SUB
+	SBC
+	CMP
+	NEG
+	INC
+	DEC
+	MUL
+	DIV
+	MOD
+	MULW
+	DIVW
+	MODW
 	OR
 	AND
 	XOR
 	ROTR
 	ROTL
+	SHL
+	SHR
+	ASR
+	BSET
+	BCLR
+	BTST
 	JMP
 	HALT
 	JMPA
+	LOOP
+	JZ
+	JNZ
+	JC
+	JNC
+	JN
+	JNN
+	JV
+	JNV
+	BRA
+	BSR
+	LBRA
+	LBSR
+	BZ
+	BNZ
+	BC
+	BNC
+	BN
+	BNN
+	BV
+	BNV
 	LDSP
 	PUSH
 	POP
+	PUSHA
+	POPA
+	PUSHF
+	POPF
 	CALL
 	RTN
+	CALLZ
+	CALLNZ
+	CALLC
+	CALLNC
+	CALLN
+	CALLNN
+	CALLV
+	CALLNV
+	RTNZ
+	RTNNZ
+	RTNC
+	RTNNC
+	RTNN
+	RTNNN
+	RTNV
+	RTNNV
+	EI
+	DI
+	RTI
+	WAI
 	RMB
 	ORG
 	FCB
@@ -57,6 +131,9 @@ var tokens = [...]string{
 	COMMENT:   "COMMENT",
 	END_INST:  "END_INST",
 	COMMA:     ",",
+	PLUS:      "+",
+	LBRACKET:  "[",
+	RBRACKET:  "]",
 	IMMEDIATE: "#",
 
 	// Identifiers & literals
@@ -71,24 +148,103 @@ var tokens = [...]string{
 	LOAD: "LOAD",
 	STR:  "STR",
 	XFER: "XFER",
+
+	LOADS: "LOADS",
+	LOADZ: "LOADZ",
+	XFERS: "XFERS",
+	XFERZ: "XFERZ",
+	TRUNC: "TRUNC",
+
+	MEMCPY: "MEMCPY",
+	MEMSET: "MEMSET",
+
 	ADD:  "ADD",
+	ADC:  "ADC",
+	SUB:  "SUB",
+	SBC:  "SBC",
+	CMP:  "CMP",
+	NEG:  "NEG",
+	INC:  "INC",
+	DEC:  "DEC",
+	MUL:  "MUL",
+	DIV:  "DIV",
+	MOD:  "MOD",
+	MULW: "MULW",
+	DIVW: "DIVW",
+	MODW: "MODW",
 	OR:   "OR",
 	AND:  "AND",
 	XOR:  "XOR",
 	ROTR: "ROTR",
 	ROTL: "ROTL",
+	SHL:  "SHL",
+	SHR:  "SHR",
+	ASR:  "ASR",
+	BSET: "BSET",
+	BCLR: "BCLR",
+	BTST: "BTST",
 	JMP:  "JMP",
 	HALT: "HALT",
 	JMPA: "JMPA",
+	LOOP: "LOOP",
+	JZ:   "JZ",
+	JNZ:  "JNZ",
+	JC:   "JC",
+	JNC:  "JNC",
+	JN:   "JN",
+	JNN:  "JNN",
+	JV:   "JV",
+	JNV:  "JNV",
+	BRA:  "BRA",
+	BSR:  "BSR",
+	LBRA: "LBRA",
+	LBSR: "LBSR",
+	BZ:   "BZ",
+	BNZ:  "BNZ",
+	BC:   "BC",
+	BNC:  "BNC",
+	BN:   "BN",
+	BNN:  "BNN",
+	BV:   "BV",
+	BNV:  "BNV",
 	LDSP: "LDSP",
 	PUSH: "PUSH",
 	POP:  "POP",
+
+	PUSHA: "PUSHA",
+	POPA:  "POPA",
+	PUSHF: "PUSHF",
+	POPF:  "POPF",
+
 	CALL: "CALL",
 	RTN:  "RTN",
-	RMB:  "RMB",
-	ORG:  "ORG",
-	FCB:  "FCB",
-	FDB:  "FDB",
+
+	CALLZ:  "CALLZ",
+	CALLNZ: "CALLNZ",
+	CALLC:  "CALLC",
+	CALLNC: "CALLNC",
+	CALLN:  "CALLN",
+	CALLNN: "CALLNN",
+	CALLV:  "CALLV",
+	CALLNV: "CALLNV",
+	RTNZ:   "RTNZ",
+	RTNNZ:  "RTNNZ",
+	RTNC:   "RTNC",
+	RTNNC:  "RTNNC",
+	RTNN:   "RTNN",
+	RTNNN:  "RTNNN",
+	RTNV:   "RTNV",
+	RTNNV:  "RTNNV",
+
+	EI:  "EI",
+	DI:  "DI",
+	RTI: "RTI",
+	WAI: "WAI",
+
+	RMB: "RMB",
+	ORG: "ORG",
+	FCB: "FCB",
+	FDB: "FDB",
 }
 
 // Opcodes maps strings to an opcode byte value
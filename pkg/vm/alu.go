@@ -0,0 +1,124 @@
+package vm
+
+// aluAdd adds a and b at the width of register r and sets all flags.
+func (vm *VM) aluAdd(r Register, a, b uint16) uint16 {
+	return vm.aluAddCarry(r, a, b, false)
+}
+
+// aluAddCarry adds a, b and the carry bit at the width of register r
+// and sets all flags.
+func (vm *VM) aluAddCarry(r Register, a, b uint16, carry bool) uint16 {
+	mask, sign := widthMask(r)
+	a, b = a&mask, b&mask
+
+	sum := uint32(a) + uint32(b)
+	if carry {
+		sum++
+	}
+	res := uint16(sum) & mask
+
+	vm.setFlag(FlagCarry, sum > uint32(mask))
+	vm.setFlag(FlagOverflow, (a^res)&(b^res)&sign != 0)
+	vm.setZN(r, res)
+	return res
+}
+
+// aluSub subtracts b from a at the width of register r and sets all flags.
+// The carry flag is set when the subtraction borrows.
+func (vm *VM) aluSub(r Register, a, b uint16) uint16 {
+	return vm.aluSubBorrow(r, a, b, false)
+}
+
+// aluSubBorrow subtracts b and the borrow bit from a at the width of
+// register r and sets all flags. The carry flag is set when the
+// subtraction borrows.
+func (vm *VM) aluSubBorrow(r Register, a, b uint16, borrow bool) uint16 {
+	mask, sign := widthMask(r)
+	a, b = a&mask, b&mask
+
+	sub := uint32(b)
+	if borrow {
+		sub++
+	}
+	res := (a - uint16(sub)) & mask
+
+	vm.setFlag(FlagCarry, sub > uint32(a))
+	vm.setFlag(FlagOverflow, (a^b)&(a^res)&sign != 0)
+	vm.setZN(r, res)
+	return res
+}
+
+// aluMul multiplies a and b at the width of register r and sets all flags.
+// The carry and overflow flags are set when the product doesn't fit in r.
+func (vm *VM) aluMul(r Register, a, b uint16) uint16 {
+	mask, _ := widthMask(r)
+	a, b = a&mask, b&mask
+
+	prod := uint32(a) * uint32(b)
+	res := uint16(prod) & mask
+
+	vm.setFlag(FlagCarry, prod > uint32(mask))
+	vm.setFlag(FlagOverflow, prod > uint32(mask))
+	vm.setZN(r, res)
+	return res
+}
+
+type shiftKind int
+
+const (
+	shiftLeft  shiftKind = iota // Shift left, filling with zeros
+	shiftRight                  // Shift right, filling with zeros
+	shiftArith                  // Shift right, filling with the sign bit
+)
+
+// aluShift shifts v by n bits at the width of register r. Z and N are set
+// from the result, C is set to the last bit shifted out and V is cleared.
+// Shifting by 0 leaves C unchanged.
+func (vm *VM) aluShift(r Register, v, n uint16, kind shiftKind) uint16 {
+	mask, sign := widthMask(r)
+	v &= mask
+
+	// Anything past the register width gives the same result
+	if n > 17 {
+		n = 17
+	}
+
+	carry := vm.isFlagSet(FlagCarry)
+	for ; n > 0; n-- {
+		switch kind {
+		case shiftLeft:
+			carry = v&sign != 0
+			v = (v << 1) & mask
+		case shiftRight:
+			carry = v&1 != 0
+			v >>= 1
+		case shiftArith:
+			carry = v&1 != 0
+			v = v>>1 | v&sign
+		}
+	}
+
+	vm.aluLogic(r, v)
+	vm.setFlag(FlagCarry, carry)
+	return v
+}
+
+// aluBit returns the mask for bit n of a value width bits wide. The zero
+// flag is set if that bit of v is clear, other flags are not changed.
+func (vm *VM) aluBit(v uint16, width, n uint8) uint16 {
+	bit := uint16(1) << (n % width)
+	vm.setFlag(FlagZero, v&bit == 0)
+	return bit
+}
+
+// aluLogic sets the zero and negative flags for the result of a logic
+// operation at the width of register r. Carry and overflow are cleared.
+func (vm *VM) aluLogic(r Register, v uint16) uint16 {
+	mask, _ := widthMask(r)
+	v &= mask
+
+	vm.setFlag(FlagCarry, false)
+	vm.setFlag(FlagOverflow, false)
+	vm.setZN(r, v)
+	return v
+}
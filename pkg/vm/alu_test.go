@@ -0,0 +1,167 @@
+package vm
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestALUAddSub(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(vm *VM, r Register, a, b uint16) uint16
+		r     Register
+		a, b  uint16
+		res   uint16
+		flags Flag
+	}{
+		{"add", (*VM).aluAdd, Register1, 0x01, 0x02, 0x03, 0},
+		{"add zero", (*VM).aluAdd, Register1, 0x00, 0x00, 0x00, FlagZero},
+		{"add carry", (*VM).aluAdd, Register1, 0xFF, 0x01, 0x00, FlagZero | FlagCarry},
+		{"add overflow", (*VM).aluAdd, Register1, 0x7F, 0x01, 0x80, FlagNegative | FlagOverflow},
+		{"add negative overflow", (*VM).aluAdd, Register1, 0x80, 0x80, 0x00, FlagZero | FlagCarry | FlagOverflow},
+		{"add double", (*VM).aluAdd, RegisterA, 0x00FF, 0x0001, 0x0100, 0},
+		{"add double carry", (*VM).aluAdd, RegisterA, 0xFFFF, 0x0002, 0x0001, FlagCarry},
+		{"add double overflow", (*VM).aluAdd, RegisterA, 0x7FFF, 0x0001, 0x8000, FlagNegative | FlagOverflow},
+
+		{"sub", (*VM).aluSub, Register1, 0x05, 0x03, 0x02, 0},
+		{"sub zero", (*VM).aluSub, Register1, 0x05, 0x05, 0x00, FlagZero},
+		{"sub borrow", (*VM).aluSub, Register1, 0x03, 0x05, 0xFE, FlagNegative | FlagCarry},
+		{"sub overflow", (*VM).aluSub, Register1, 0x80, 0x01, 0x7F, FlagOverflow},
+		{"sub negative overflow", (*VM).aluSub, Register1, 0x7F, 0xFF, 0x80, FlagNegative | FlagCarry | FlagOverflow},
+		{"sub double borrow", (*VM).aluSub, RegisterA, 0x0000, 0x0001, 0xFFFF, FlagNegative | FlagCarry},
+	}
+
+	for _, test := range tests {
+		vm := &VM{}
+		res := test.op(vm, test.r, test.a, test.b)
+		if res != test.res {
+			t.Errorf("%s: got 0x%X, expected 0x%X", test.name, res, test.res)
+		}
+		if vm.flags != uint8(test.flags) {
+			t.Errorf("%s: got flags %s, expected %s", test.name, formatFlags(vm.flags), formatFlags(uint8(test.flags)))
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		flags Flag
+	}{
+		{"5", "5", FlagZero},
+		{"5", "3", 0},
+		{"3", "5", FlagNegative | FlagCarry},
+		{"0x80", "1", FlagOverflow},
+	}
+
+	for _, test := range tests {
+		sim := runSource(t, "LOAD %1 #"+test.a+"\nCMP %1 #"+test.b+"\nHALT\n")
+		if sim.flags != uint8(test.flags) {
+			t.Errorf("CMP %s %s: got flags %s, expected %s", test.a, test.b, formatFlags(sim.flags), formatFlags(uint8(test.flags)))
+		}
+		a, _ := strconv.ParseUint(test.a, 0, 8)
+		if v := sim.readSingleReg(Register1); v != uint8(a) {
+			t.Errorf("CMP %s %s: changed the register to 0x%X", test.a, test.b, v)
+		}
+	}
+}
+
+func TestALUShift(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     Register
+		v, n  uint16
+		kind  shiftKind
+		res   uint16
+		flags Flag
+	}{
+		{"shl", Register1, 0x41, 1, shiftLeft, 0x82, FlagNegative},
+		{"shl carry", Register1, 0x81, 1, shiftLeft, 0x02, FlagCarry},
+		{"shl to zero", Register1, 0x80, 1, shiftLeft, 0x00, FlagZero | FlagCarry},
+		{"shl by 0", Register1, 0x81, 0, shiftLeft, 0x81, FlagNegative},
+		{"shl past width", Register1, 0xFF, 9, shiftLeft, 0x00, FlagZero},
+		{"shl double", RegisterA, 0x8001, 4, shiftLeft, 0x0010, 0},
+		{"shr", Register1, 0x82, 1, shiftRight, 0x41, 0},
+		{"shr carry", Register1, 0x03, 1, shiftRight, 0x01, FlagCarry},
+		{"shr double", RegisterA, 0x8000, 15, shiftRight, 0x0001, 0},
+		{"asr", Register1, 0x80, 1, shiftArith, 0xC0, FlagNegative},
+		{"asr carry", Register1, 0x81, 1, shiftArith, 0xC0, FlagNegative | FlagCarry},
+		{"asr past width", Register1, 0x80, 20, shiftArith, 0xFF, FlagNegative | FlagCarry},
+		{"asr positive", RegisterA, 0x4000, 14, shiftArith, 0x0001, 0},
+	}
+
+	for _, test := range tests {
+		vm := &VM{flags: uint8(FlagOverflow)}
+		res := vm.aluShift(test.r, test.v, test.n, test.kind)
+		if res != test.res {
+			t.Errorf("%s: got 0x%X, expected 0x%X", test.name, res, test.res)
+		}
+		if vm.flags != uint8(test.flags) {
+			t.Errorf("%s: got flags %s, expected %s", test.name, formatFlags(vm.flags), formatFlags(uint8(test.flags)))
+		}
+	}
+}
+
+func TestShiftByZeroKeepsCarry(t *testing.T) {
+	for _, kind := range []shiftKind{shiftLeft, shiftRight, shiftArith} {
+		vm := &VM{flags: uint8(FlagCarry | FlagOverflow)}
+		res := vm.aluShift(Register1, 0x42, 0, kind)
+		if res != 0x42 {
+			t.Errorf("kind %d: got 0x%X, expected 0x42", kind, res)
+		}
+		if vm.flags != uint8(FlagCarry) {
+			t.Errorf("kind %d: got flags %s, expected %s", kind, formatFlags(vm.flags), formatFlags(uint8(FlagCarry)))
+		}
+	}
+}
+
+func TestCarryChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		hi, lo uint16
+		flags  Flag
+	}{
+		{
+			"adc carries into the high word",
+			"LOAD %A #0xFFFF\nLOAD %B #0x0001\nADD %A #1\nADC %B #0",
+			0x0002, 0x0000, 0,
+		},
+		{
+			"adc carries out of the high word",
+			"LOAD %A #0xFFFF\nLOAD %B #0xFFFF\nADD %A #1\nADC %B #0",
+			0x0000, 0x0000, FlagZero | FlagCarry,
+		},
+		{
+			"adc without carry",
+			"LOAD %A #0x1234\nLOAD %B #0x0001\nADD %A #1\nADC %B #0",
+			0x0001, 0x1235, 0,
+		},
+		{
+			"sbc borrows from the high word",
+			"LOAD %A #0x0000\nLOAD %B #0x0002\nSUB %A #1\nSBC %B #0",
+			0x0001, 0xFFFF, 0,
+		},
+		{
+			"sbc borrows out of the high word",
+			"LOAD %A #0x0000\nLOAD %B #0x0000\nSUB %A #1\nSBC %B #0",
+			0xFFFF, 0xFFFF, FlagNegative | FlagCarry,
+		},
+		{
+			"sbc overflow",
+			"LOAD %A #0x0000\nLOAD %B #0x8000\nSUB %A #1\nSBC %B #0",
+			0x7FFF, 0xFFFF, FlagOverflow,
+		},
+	}
+
+	for _, test := range tests {
+		sim := runSource(t, test.src+"\nHALT\n")
+		hi, lo := sim.ReadReg(RegisterB), sim.ReadReg(RegisterA)
+		if hi != test.hi || lo != test.lo {
+			t.Errorf("%s: got 0x%04X%04X, expected 0x%04X%04X", test.name, hi, lo, test.hi, test.lo)
+		}
+		if sim.flags != uint8(test.flags) {
+			t.Errorf("%s: got flags %s, expected %s", test.name, formatFlags(sim.flags), formatFlags(uint8(test.flags)))
+		}
+	}
+}
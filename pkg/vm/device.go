@@ -0,0 +1,96 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PrinterAddr is the address of the machine printer. A character written to
+// it is printed.
+const PrinterAddr = 0xFFFD
+
+// Device is a memory mapped peripheral attached to the machine bus. Reads and
+// writes to addresses in the device's range go to the device instead of memory.
+// Devices that implement io.Closer are closed when the machine halts.
+type Device interface {
+	// Range returns the first and last address the device responds to.
+	Range() (start, end uint16)
+
+	Read(addr uint16) uint8
+	Write(addr uint16, v uint8)
+
+	// Tick is called after every instruction with the number of cycles
+	// the instruction took.
+	Tick(cycles uint64)
+}
+
+// deviceAt returns the device mapped at addr, or nil if the address is
+// plain memory. When device ranges overlap, the first attached device wins.
+func (vm *VM) deviceAt(addr uint16) Device {
+	for _, d := range vm.devices {
+		start, end := d.Range()
+		if addr >= start && addr <= end {
+			return d
+		}
+	}
+	return nil
+}
+
+func (vm *VM) busRead(addr uint16) uint8 {
+	if d := vm.deviceAt(addr); d != nil {
+		return d.Read(addr)
+	}
+	return vm.memory[addr]
+}
+
+func (vm *VM) busWrite(addr uint16, v uint8) {
+	if d := vm.deviceAt(addr); d != nil {
+		d.Write(addr, v)
+		return
+	}
+	vm.memory[addr] = v
+}
+
+func (vm *VM) tickDevices(cycles uint64) {
+	for _, d := range vm.devices {
+		d.Tick(cycles)
+	}
+}
+
+func (vm *VM) closeDevices() {
+	for _, d := range vm.devices {
+		if c, ok := d.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// printer prints the characters written to PrinterAddr. Characters are
+// written to w as they're printed, or collected in buf until the machine
+// halts if w is nil. Writing 0 prints nothing and the address always
+// reads as 0.
+type printer struct {
+	w     io.Writer
+	buf   bytes.Buffer
+	state bool // Write each character on its own line between state dumps
+}
+
+func (p *printer) Range() (start, end uint16) { return PrinterAddr, PrinterAddr }
+func (p *printer) Read(addr uint16) uint8     { return 0 }
+func (p *printer) Tick(cycles uint64)         {}
+
+func (p *printer) Write(addr uint16, v uint8) {
+	if v == 0 {
+		return
+	}
+	if p.w != nil {
+		if p.state {
+			fmt.Fprintf(p.w, "Printer: %c\n", v)
+		} else {
+			p.w.Write([]byte{v})
+		}
+	} else {
+		p.buf.WriteByte(v)
+	}
+}
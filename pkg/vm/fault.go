@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FaultKind int
+
+// Fault kinds
+const (
+	FaultInvalidOpcode FaultKind = iota
+	FaultInvalidRegister
+	FaultStackOverflow
+	FaultStackUnderflow
+	FaultPCWraparound
+	FaultDivideByZero
+	FaultProtectedWrite
+	FaultRegisterWidth
+)
+
+var faultNames = [...]string{
+	FaultInvalidOpcode:   "invalid opcode",
+	FaultInvalidRegister: "invalid register",
+	FaultStackOverflow:   "stack overflow",
+	FaultStackUnderflow:  "stack underflow",
+	FaultPCWraparound:    "program counter wraparound",
+	FaultDivideByZero:    "divide by zero",
+	FaultProtectedWrite:  "protected write",
+	FaultRegisterWidth:   "register width mismatch",
+}
+
+func (k FaultKind) String() string {
+	if 0 <= k && int(k) < len(faultNames) {
+		return faultNames[k]
+	}
+	return fmt.Sprintf("fault(%d)", int(k))
+}
+
+// Fault is returned by Run when the machine stops because of an error.
+// It holds the state of the machine at the time of the fault.
+type Fault struct {
+	Kind      FaultKind
+	PC        uint16 // Address of the faulting instruction
+	Opcode    byte
+	Addr      uint16 // Memory address of a protected write
+	Registers [numOfRegisters]uint8
+	SP        uint16
+	Flags     uint8
+}
+
+func (f *Fault) Error() string {
+	msg := fmt.Sprintf("%s at 0x%04X (opcode 0x%02X)", f.Kind, f.PC, f.Opcode)
+	if f.Kind == FaultProtectedWrite {
+		msg += fmt.Sprintf(" writing 0x%04X", f.Addr)
+	}
+	return msg
+}
+
+// faultStop is panicked by a fault while an instruction is running to stop
+// the rest of the instruction. It's recovered by runInstruction.
+type faultStop struct{}
+
+// runInstruction calls fn, stopping it where it is if the machine faults.
+func (vm *VM) runInstruction(fn func()) {
+	vm.running = true
+	defer func() {
+		vm.running = false
+		if r := recover(); r != nil {
+			if _, ok := r.(faultStop); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn()
+}
+
+// fault stops the machine with a fault of the given kind. If an instruction
+// is running, it's stopped and has no further effects.
+func (vm *VM) fault(kind FaultKind) {
+	vm.faultAddr(kind, 0)
+}
+
+func (vm *VM) faultAddr(kind FaultKind, addr uint16) {
+	if vm.halted {
+		return
+	}
+
+	f := &Fault{
+		Kind:   kind,
+		PC:     vm.instPC,
+		Opcode: vm.memory[vm.instPC],
+		Addr:   addr,
+		SP:     vm.sp,
+		Flags:  vm.flags,
+	}
+	copy(f.Registers[:], vm.registers)
+	vm.err = f
+
+	vm.writeString(strings.ToUpper(kind.String()) + "\n")
+	vm.halt()
+
+	if vm.running {
+		panic(faultStop{})
+	}
+}
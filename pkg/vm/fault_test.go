@@ -0,0 +1,200 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/lfkeitel/asml-sim/pkg/opcodes"
+	"github.com/lfkeitel/asml-sim/pkg/parser"
+)
+
+// faultTest runs src, or code if it's set, and checks the machine faults
+// with kind at address pc.
+type faultTest struct {
+	name string
+	src  string
+	code []byte
+	kind FaultKind
+	pc   uint16
+	opts []Option
+}
+
+func checkFault(t *testing.T, sim *VM, test faultTest) {
+	t.Helper()
+
+	err := runTestVM(t, sim)
+	f, ok := err.(*Fault)
+	if !ok {
+		t.Errorf("%s: expected a fault, got %v", test.name, err)
+		return
+	}
+	if f.Kind != test.kind {
+		t.Errorf("%s: got %s fault, expected %s", test.name, f.Kind, test.kind)
+	}
+	if f.PC != test.pc {
+		t.Errorf("%s: got fault at 0x%04X, expected 0x%04X", test.name, f.PC, test.pc)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []faultTest{
+		{name: "invalid opcode", src: "FCB 0xEE", kind: FaultInvalidOpcode, pc: 0},
+		{name: "invalid register", code: []byte{opcodes.LOADI, 0x20, 0x00, 0x01}, kind: FaultInvalidRegister, pc: 0},
+		{name: "divide by zero", src: "LOAD %1 #1\nDIV %1 #0", kind: FaultDivideByZero, pc: 4},
+		{name: "divw by zero", src: "LOAD %A #1\nLOAD %1 #0\nDIVW %0 %A %1", kind: FaultDivideByZero, pc: 8},
+		{name: "pc wraparound", src: "ORG 0xFFFE\nFDB 0xFFFF", kind: FaultPCWraparound, pc: 0xFFFF},
+		{name: "pop past top of memory", src: "LDSP #0xFFFF\nPOP %A", kind: FaultStackUnderflow, pc: 3},
+		{name: "push with no stack", src: "PUSH %1", kind: FaultStackOverflow, pc: 0},
+		{name: "push 16 at 0", src: "LDSP #4\nPUSH %A\nPUSH %A\nPUSH %A", kind: FaultStackOverflow, pc: 7},
+		{name: "push 16 at 1", src: "LDSP #1\nPUSH %A", kind: FaultStackOverflow, pc: 3},
+		{name: "push 8 at 0", src: "LDSP #2\nPUSH %1\nPUSH %1\nPUSH %1", kind: FaultStackOverflow, pc: 7},
+		{name: "call at 0", src: "LDSP #0\nCALL sub\n:sub\nRTN", kind: FaultStackOverflow, pc: 3},
+		{
+			name: "protected write",
+			src:  "LOAD %1 #1\nSTR %1 0x1000",
+			kind: FaultProtectedWrite,
+			pc:   4,
+			opts: []Option{WithProtectedRange(0x1000, 0x10FF)},
+		},
+		{
+			// The assembler rejects mismatched widths, so the code is built by hand
+			name: "strict width",
+			code: []byte{opcodes.ADDR, 0x0A, 0x01},
+			kind: FaultRegisterWidth,
+			pc:   0,
+			opts: []Option{WithStrict(true)},
+		},
+	}
+
+	for _, test := range tests {
+		var sim *VM
+		if test.code != nil {
+			sim = newCodeVM(t, test.code, test.opts...)
+		} else {
+			sim = newTestVM(t, test.src+"\n", test.opts...)
+		}
+		checkFault(t, sim, test)
+	}
+}
+
+func TestStackFillsToZero(t *testing.T) {
+	sim := runSource(t, `
+	LDSP #4
+	LOAD %A #0x1234
+	LOAD %B #0x5678
+	PUSH %A
+	PUSH %B
+	POP %C
+	POP %D
+	HALT
+`)
+
+	if sim.sp != 4 {
+		t.Errorf("got SP 0x%04X, expected 0x0004", sim.sp)
+	}
+	if c, d := sim.ReadReg(RegisterC), sim.ReadReg(RegisterD); c != 0x5678 || d != 0x1234 {
+		t.Errorf("got C 0x%04X and D 0x%04X, expected 0x5678 and 0x1234", c, d)
+	}
+}
+
+// newCodeVM creates a machine running code at address 0.
+func newCodeVM(t *testing.T, code []byte, opts ...Option) *VM {
+	t.Helper()
+
+	sim, err := New([]parser.CodePart{{Bytes: code}}, opts...)
+	if err != nil {
+		t.Fatalf("creating machine failed: %v", err)
+	}
+	return sim
+}
+
+func TestFaultStopsInstruction(t *testing.T) {
+	t.Run("memset", func(t *testing.T) {
+		sim := newTestVM(t, `
+	LOAD %A #0x1000
+	LOAD %1 #0xAA
+	LOAD %B #16
+	MEMSET %A %1 %B
+	HALT
+`, WithProtectedRange(0x1004, 0x1005))
+
+		checkFault(t, sim, faultTest{name: "memset", kind: FaultProtectedWrite, pc: 12})
+		for addr := uint16(0x1000); addr < 0x1010; addr++ {
+			expected := uint8(0)
+			if addr < 0x1004 {
+				expected = 0xAA
+			}
+			if v := sim.memory[addr]; v != expected {
+				t.Errorf("memory 0x%04X is 0x%02X, expected 0x%02X", addr, v, expected)
+			}
+		}
+		// 3 loads and the MEMSET, plus 4 bytes written
+		if sim.cycles != 8 {
+			t.Errorf("got %d cycles, expected 8", sim.cycles)
+		}
+	})
+
+	t.Run("memcpy", func(t *testing.T) {
+		sim := newTestVM(t, `
+	LOAD %A #0x1000
+	LOAD %B #0x2000
+	LOAD %C #8
+	MEMCPY %A %B %C
+	HALT
+`, WithProtectedRange(0x1002, 0x1002))
+		for i := uint16(0); i < 8; i++ {
+			sim.memory[0x2000+i] = uint8(i + 1)
+		}
+
+		checkFault(t, sim, faultTest{name: "memcpy", kind: FaultProtectedWrite, pc: 12})
+		for addr := uint16(0x1000); addr < 0x1008; addr++ {
+			expected := uint8(0)
+			if addr < 0x1002 {
+				expected = uint8(addr-0x1000) + 1
+			}
+			if v := sim.memory[addr]; v != expected {
+				t.Errorf("memory 0x%04X is 0x%02X, expected 0x%02X", addr, v, expected)
+			}
+		}
+	})
+
+	t.Run("pc wraparound", func(t *testing.T) {
+		// LOAD %1 #0xFFFC at 0xFFFC, the immediate doubles as the reset vector
+		code := parser.CodePart{
+			StartPC: 0xFFFC,
+			Bytes:   []byte{opcodes.LOADI, 0x01, 0xFF, 0xFC},
+		}
+		sim, err := New([]parser.CodePart{code})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkFault(t, sim, faultTest{name: "pc wraparound", kind: FaultPCWraparound, pc: 0xFFFC})
+		if v := sim.ReadReg(Register1); v != 0 {
+			t.Errorf("register 1 was loaded with 0x%02X", v)
+		}
+	})
+
+	t.Run("invalid register", func(t *testing.T) {
+		sim := newCodeVM(t, []byte{
+			opcodes.LOADI, 0x01, 0x00, 0x05,
+			opcodes.XFER, 0x01, 0x20,
+		})
+
+		checkFault(t, sim, faultTest{name: "invalid register", kind: FaultInvalidRegister, pc: 4})
+		if v := sim.ReadReg(Register1); v != 5 {
+			t.Errorf("register 1 was changed to 0x%02X", v)
+		}
+	})
+
+	t.Run("strict width", func(t *testing.T) {
+		sim := newCodeVM(t, []byte{
+			opcodes.LOADI, 0x01, 0x00, 0x05,
+			opcodes.XFER, 0x0A, 0x01,
+		}, WithStrict(true))
+
+		checkFault(t, sim, faultTest{name: "strict width", kind: FaultRegisterWidth, pc: 4})
+		if v := sim.ReadReg(RegisterA); v != 0 {
+			t.Errorf("register A was changed to 0x%04X", v)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package vm
+
+type Flag uint8
+
+// Condition code flags
+const (
+	FlagZero      Flag = 1 << iota // Result was zero
+	FlagNegative                   // High bit of the result was set
+	FlagCarry                      // Unsigned carry out of the result
+	FlagOverflow                   // Signed overflow
+	FlagInterrupt                  // Maskable interrupts are enabled
+)
+
+var flagNames = []struct {
+	f    Flag
+	name byte
+}{
+	{FlagZero, 'Z'},
+	{FlagNegative, 'N'},
+	{FlagCarry, 'C'},
+	{FlagOverflow, 'V'},
+	{FlagInterrupt, 'I'},
+}
+
+func (vm *VM) setFlag(f Flag, set bool) {
+	if set {
+		vm.flags |= uint8(f)
+	} else {
+		vm.flags &^= uint8(f)
+	}
+}
+
+func (vm *VM) isFlagSet(f Flag) bool {
+	return vm.flags&uint8(f) != 0
+}
+
+// setZN sets the zero and negative flags based on a value
+// the width of register r.
+func (vm *VM) setZN(r Register, v uint16) {
+	mask, sign := widthMask(r)
+	vm.setFlag(FlagZero, v&mask == 0)
+	vm.setFlag(FlagNegative, v&sign != 0)
+}
+
+// widthMask returns the value mask and sign bit for the width of register r.
+func widthMask(r Register) (mask, sign uint16) {
+	if IsDoubleReg(r) {
+		return 0xFFFF, 0x8000
+	}
+	return 0xFF, 0x80
+}
+
+func formatFlags(flags uint8) string {
+	s := make([]byte, len(flagNames))
+	for i, f := range flagNames {
+		if flags&uint8(f.f) != 0 {
+			s[i] = f.name
+		} else {
+			s[i] = '-'
+		}
+	}
+	return string(s)
+}
@@ -0,0 +1,179 @@
+package vm
+
+import (
+	"io"
+	"sync"
+)
+
+// Console input addresses
+const (
+	InputStatusAddr = 0xFFF0
+	InputDataAddr   = 0xFFF1
+)
+
+// Console input status bits
+const (
+	InputReady = 1 << iota // A byte is available in the data register
+	InputEOF               // The input has ended
+
+	// Writing InputIRQ to the status address makes the device request an
+	// interrupt while a byte is available or after the input has ended.
+	// Writing 0 turns it off.
+	InputIRQ = 0x80
+)
+
+// Input is a console input device. Reading the status address returns the
+// InputReady and InputEOF bits, reading the data address returns the next
+// input byte, or 0 if there isn't one.
+//
+// A blocking device reads from the reader when the status or data address
+// is read, or when it's asked for an interrupt, and waits for input to
+// arrive. It only takes one byte at a time from the reader.
+//
+// A non-blocking device reads ahead from the reader in the background and
+// returns immediately with whatever input has arrived. Reading ahead stops
+// when the device is closed, the machine closes it when it halts.
+type Input struct {
+	r        io.Reader
+	blocking bool
+	irq      bool
+
+	next    byte
+	pending bool
+	eof     bool
+
+	mu      sync.Mutex // Guards the fields below, shared with readAhead
+	started bool
+	closed  bool
+	buf     []byte
+	err     error
+}
+
+// NewInput creates a console input device reading from r.
+func NewInput(r io.Reader, blocking bool) *Input {
+	return &Input{
+		r:        r,
+		blocking: blocking,
+	}
+}
+
+func (in *Input) Range() (start, end uint16) { return InputStatusAddr, InputDataAddr }
+func (in *Input) Tick(cycles uint64)         {}
+
+func (in *Input) Write(addr uint16, v uint8) {
+	if addr == InputStatusAddr {
+		in.irq = v&InputIRQ != 0
+	}
+}
+
+// IRQ requests an interrupt while a byte is available or after the input
+// has ended, if interrupts are turned on.
+func (in *Input) IRQ() bool {
+	if !in.irq {
+		return false
+	}
+	in.fill()
+	return in.pending || in.eof
+}
+
+func (in *Input) Read(addr uint16) uint8 {
+	in.fill()
+
+	switch addr {
+	case InputStatusAddr:
+		var status uint8
+		if in.pending {
+			status |= InputReady
+		}
+		if in.eof {
+			status |= InputEOF
+		}
+		return status
+	case InputDataAddr:
+		if !in.pending {
+			return 0
+		}
+		in.pending = false
+		return in.next
+	}
+	return 0
+}
+
+// Close stops a non-blocking device from reading ahead. Input that was
+// already read can still be read from the device. A read from the reader
+// that's in progress isn't interrupted, the reader should be closed to
+// end it.
+func (in *Input) Close() error {
+	in.mu.Lock()
+	in.closed = true
+	in.mu.Unlock()
+	return nil
+}
+
+// fill takes the next byte of input if one isn't already pending.
+func (in *Input) fill() {
+	if in.pending || in.eof {
+		return
+	}
+	if in.blocking {
+		in.readByte()
+	} else {
+		in.takeBuffered()
+	}
+}
+
+// readByte waits for the next byte from the reader.
+func (in *Input) readByte() {
+	var b [1]byte
+	for {
+		n, err := in.r.Read(b[:])
+		if n > 0 {
+			in.next = b[0]
+			in.pending = true
+			return
+		}
+		if err != nil {
+			in.eof = true
+			return
+		}
+	}
+}
+
+// takeBuffered takes the next byte read ahead by readAhead, starting it
+// the first time it's called.
+func (in *Input) takeBuffered() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if !in.started && !in.closed {
+		in.started = true
+		go in.readAhead()
+	}
+
+	if len(in.buf) > 0 {
+		in.next = in.buf[0]
+		in.buf = in.buf[1:]
+		in.pending = true
+	} else if in.err != nil {
+		in.eof = true
+	}
+}
+
+// readAhead buffers input from the reader until the reader returns an
+// error or the device is closed.
+func (in *Input) readAhead() {
+	b := make([]byte, 256)
+	for {
+		n, err := in.r.Read(b)
+
+		in.mu.Lock()
+		in.buf = append(in.buf, b[:n]...)
+		in.err = err
+		done := err != nil || in.closed
+		in.mu.Unlock()
+
+		if done {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const echoSource = `
+:wait
+BTST 0xFFF0 #1
+BNZ done
+BTST 0xFFF0 #0
+BZ wait
+LOAD %1 0xFFF1
+STR %1 0xFFFD
+BRA wait
+:done
+HALT
+`
+
+func TestInputEcho(t *testing.T) {
+	for _, blocking := range []bool{true, false} {
+		var out bytes.Buffer
+		in := NewInput(strings.NewReader("hello"), blocking)
+		runSource(t, echoSource, WithOutput(&bytes.Buffer{}), WithPrinterOutput(&out), WithDevice(in))
+
+		if out.String() != "hello" {
+			t.Errorf("blocking %t: printed %q, expected %q", blocking, out.String(), "hello")
+		}
+	}
+}
+
+func TestInputBlockingTakesOneByte(t *testing.T) {
+	r := strings.NewReader("abc")
+	in := NewInput(r, true)
+	sim := runSource(t, "LOAD %1 0xFFF1\nHALT\n", WithOutput(&bytes.Buffer{}), WithDevice(in))
+
+	if sim.registers[1] != 'a' {
+		t.Errorf("read %q, expected %q", sim.registers[1], 'a')
+	}
+	if r.Len() != 2 {
+		t.Errorf("%d bytes left in the reader, expected 2", r.Len())
+	}
+}
+
+func TestInputEOF(t *testing.T) {
+	in := NewInput(strings.NewReader(""), true)
+	sim := runSource(t, "LOAD %0 0xFFF0\nLOAD %1 0xFFF1\nHALT\n", WithOutput(&bytes.Buffer{}), WithDevice(in))
+
+	if sim.registers[0] != InputEOF {
+		t.Errorf("status %#x, expected %#x", sim.registers[0], InputEOF)
+	}
+	if sim.registers[1] != 0 {
+		t.Errorf("data %#x, expected 0", sim.registers[1])
+	}
+}
+
+func TestInputClosedOnHalt(t *testing.T) {
+	in := NewInput(strings.NewReader(""), false)
+	runSource(t, "HALT\n", WithOutput(&bytes.Buffer{}), WithDevice(in))
+
+	if !in.closed {
+		t.Error("input wasn't closed when the machine halted")
+	}
+	// A closed device doesn't start reading ahead
+	in.Read(InputStatusAddr)
+	if in.started {
+		t.Error("closed input started reading")
+	}
+}
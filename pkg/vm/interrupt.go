@@ -0,0 +1,64 @@
+package vm
+
+import "sync/atomic"
+
+// Interrupt vectors. Each holds the address of the interrupt handler.
+const (
+	IRQVector = 0xFFF8
+	NMIVector = 0xFFFA
+)
+
+// IRQSource is implemented by devices that can request a maskable interrupt.
+// Requests are level triggered, the device should keep returning true until
+// the handler services it.
+type IRQSource interface {
+	IRQ() bool
+}
+
+// NMI requests a non-maskable interrupt. It's taken before the next
+// instruction even if interrupts are disabled. It's safe to call from
+// another goroutine while the machine is running.
+func (vm *VM) NMI() {
+	atomic.StoreInt32(&vm.nmi, 1)
+}
+
+// irqPending returns if any device is requesting an interrupt.
+func (vm *VM) irqPending() bool {
+	for _, d := range vm.devices {
+		if src, ok := d.(IRQSource); ok && src.IRQ() {
+			return true
+		}
+	}
+	return false
+}
+
+// interrupt enters the handler of a pending interrupt and returns the name
+// of the interrupt taken, or an empty string if there wasn't one.
+func (vm *VM) interrupt() string {
+	switch {
+	case atomic.CompareAndSwapInt32(&vm.nmi, 1, 0):
+		vm.enterInterrupt(NMIVector)
+		return "NMI"
+	case vm.isFlagSet(FlagInterrupt) && vm.irqPending():
+		vm.enterInterrupt(IRQVector)
+		return "IRQ"
+	}
+	return ""
+}
+
+// enterInterrupt pushes the program counter and flags, disables maskable
+// interrupts and jumps to the handler in vector.
+func (vm *VM) enterInterrupt(vector uint16) {
+	vm.waiting = false
+	vm.push16(vm.pc)
+	vm.push8(vm.flags)
+	vm.setFlag(FlagInterrupt, false)
+	vm.pc = vm.readMem16(vector)
+}
+
+// rti returns from an interrupt handler, restoring the flags and program
+// counter.
+func (vm *VM) rti() {
+	vm.flags = vm.pop8()
+	vm.pc = vm.pop16()
+}
@@ -0,0 +1,177 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// irqDevice requests an IRQ while raised is true.
+type irqDevice struct {
+	raised bool
+}
+
+func (d *irqDevice) Range() (start, end uint16) { return 0xFF00, 0xFF00 }
+func (d *irqDevice) Read(addr uint16) uint8     { return 0 }
+func (d *irqDevice) Write(addr uint16, v uint8) { d.raised = false }
+func (d *irqDevice) Tick(cycles uint64)         {}
+func (d *irqDevice) IRQ() bool                  { return d.raised }
+
+// The handler counts interrupts in %0 and acknowledges the device.
+const interruptSource = `
+    ORG 0xFFF8
+    FDB on_irq
+    FDB on_nmi
+
+    ORG 0xFFFE
+    FDB main
+
+    ORG 0x0000
+:main
+    LDSP #0x00FF
+    %s
+    HALT
+
+:on_irq
+    INC %%0
+    STR %%0 0xFF00
+    CMP %%0 #0
+    RTI
+
+:on_nmi
+    LOAD %%1 #1
+    RTI
+`
+
+func newInterruptVM(t *testing.T, main string, dev *irqDevice) *VM {
+	t.Helper()
+	return newTestVM(t, fmt.Sprintf(interruptSource, main), WithOutput(&bytes.Buffer{}), WithDevice(dev))
+}
+
+func TestIRQDelivery(t *testing.T) {
+	dev := &irqDevice{raised: true}
+	sim := newInterruptVM(t, "EI\nLOAD %1 #2", dev)
+	if err := runTestVM(t, sim); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+
+	if sim.registers[0] != 1 {
+		t.Errorf("handler ran %d times, expected 1", sim.registers[0])
+	}
+	if sim.registers[1] != 2 {
+		t.Error("execution didn't continue after the handler")
+	}
+	// RTI restores the flags from before the interrupt, the handler's
+	// compare cleared Z and entering the handler cleared I
+	if !sim.isFlagSet(FlagInterrupt) {
+		t.Error("I flag not restored by RTI")
+	}
+	if sim.isFlagSet(FlagZero) {
+		t.Error("Z flag from the handler wasn't replaced by RTI")
+	}
+}
+
+func TestIRQMasked(t *testing.T) {
+	dev := &irqDevice{raised: true}
+	sim := newInterruptVM(t, "DI\nWAI\nLOAD %1 #2", dev)
+	if err := runTestVM(t, sim); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+
+	if sim.registers[0] != 0 {
+		t.Error("handler ran with interrupts disabled")
+	}
+	if sim.registers[1] != 2 {
+		t.Error("a pending IRQ didn't wake the WAI")
+	}
+}
+
+func TestWaitUntilIRQ(t *testing.T) {
+	dev := &irqDevice{}
+	sim := newInterruptVM(t, "EI\nWAI", dev)
+
+	for i := 0; i < 5; i++ {
+		info, err := sim.Step()
+		if err != nil {
+			t.Fatalf("unexpected fault: %v", err)
+		}
+		if info.Interrupt != "" {
+			t.Fatalf("interrupt %s taken without a request", info.Interrupt)
+		}
+	}
+
+	info, _ := sim.Step()
+	if !info.Waiting {
+		t.Fatal("machine isn't waiting after WAI")
+	}
+
+	dev.raised = true
+	info, _ = sim.Step()
+	if info.Interrupt != "IRQ" {
+		t.Errorf("step took interrupt %q, expected IRQ", info.Interrupt)
+	}
+}
+
+func TestNMI(t *testing.T) {
+	dev := &irqDevice{}
+	sim := newInterruptVM(t, "DI\nLOAD %0 #0", dev)
+
+	sim.Step() // LDSP
+	sim.NMI()
+	info, err := sim.Step()
+	if err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+	if info.Interrupt != "NMI" {
+		t.Fatalf("step took interrupt %q, expected NMI", info.Interrupt)
+	}
+	if err := runTestVM(t, sim); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+	if sim.registers[1] != 1 {
+		t.Error("NMI handler didn't run")
+	}
+}
+
+func TestInterruptEchoExample(t *testing.T) {
+	src, err := ioutil.ReadFile("../../examples/interrupt echo.asml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{"", "a", "hello"} {
+		var out bytes.Buffer
+		in := NewInput(strings.NewReader(input), true)
+		runSource(t, string(src), WithOutput(&bytes.Buffer{}), WithPrinterOutput(&out), WithDevice(in))
+
+		if out.String() != input {
+			t.Errorf("printed %q, expected %q", out.String(), input)
+		}
+	}
+}
+
+func TestNMIWhileRunning(t *testing.T) {
+	sim := newTestVM(t, `
+    ORG 0xFFFA
+    FDB on_nmi
+
+    ORG 0xFFFE
+    FDB main
+
+    ORG 0x0000
+:main
+    LDSP #0x00FF
+:loop
+    BRA loop
+
+:on_nmi
+    HALT
+`, WithOutput(&bytes.Buffer{}))
+
+	go sim.NMI()
+	if err := sim.Run(); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package vm
+
+import "testing"
+
+func TestMultiplyDivide(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		r     Register
+		res   uint16
+		flags Flag
+	}{
+		{"mul truncates", "LOAD %1 #50\nLOAD %2 #100\nMUL %1 %2", Register1, 0x88, FlagCarry | FlagOverflow | FlagNegative},
+		{"mul fits", "LOAD %1 #5\nMUL %1 #6", Register1, 30, 0},
+		{"mul double", "LOAD %B #300\nLOAD %1 #100\nMUL %B %1", RegisterB, 30000, 0},
+		{"mulw", "LOAD %1 #50\nLOAD %2 #100\nMULW %A %1 %2", RegisterA, 5000, 0},
+		{"mulw max", "LOAD %1 #0xFF\nLOAD %2 #0xFF\nMULW %B %1 %2", RegisterB, 0xFE01, FlagNegative},
+		{"div", "LOAD %1 #100\nDIV %1 #7", Register1, 14, 0},
+		{"mod", "LOAD %1 #100\nMOD %1 #7", Register1, 2, 0},
+		{"div by wider immediate", "LOAD %1 #100\nDIV %1 #0x100", Register1, 0, FlagZero},
+		{"mod by wider immediate", "LOAD %1 #100\nMOD %1 #0x100", Register1, 100, 0},
+		{"div double by single", "LOAD %A #1000\nLOAD %1 #10\nDIV %A %1", RegisterA, 100, 0},
+		{"divw", "LOAD %B #1000\nLOAD %0 #10\nDIVW %1 %B %0", Register1, 100, 0},
+		{"divw quotient too big", "LOAD %B #1000\nLOAD %0 #2\nDIVW %1 %B %0", Register1, 0xF4, FlagNegative | FlagCarry | FlagOverflow},
+		{"modw", "LOAD %B #1000\nLOAD %0 #7\nMODW %1 %B %0", Register1, 6, 0},
+	}
+
+	for _, test := range tests {
+		sim := runSource(t, test.src+"\nHALT\n")
+		if v := sim.ReadReg(test.r); v != test.res {
+			t.Errorf("%s: got %d, expected %d", test.name, v, test.res)
+		}
+		if sim.flags != uint8(test.flags) {
+			t.Errorf("%s: got flags %s, expected %s", test.name, formatFlags(sim.flags), formatFlags(uint8(test.flags)))
+		}
+	}
+}
@@ -2,6 +2,8 @@ package vm
 
 import (
 	"math/bits"
+
+	"github.com/lfkeitel/asml-sim/pkg/opcodes"
 )
 
 // Opcode definitions
@@ -29,6 +31,36 @@ func (vm *VM) loadRegInMemoryAddr(d, s uint8) {
 	vm.loadFromMem(d, addr)
 }
 
+func (vm *VM) loadIndexed(r uint8, base uint16, x uint8) {
+	vm.loadFromMem(r, vm.indexAddr(Register(r), base, x))
+}
+
+func (vm *VM) loadIndirect(r uint8, ptr uint16) {
+	vm.loadFromMem(r, vm.readMem16(ptr))
+}
+
+func (vm *VM) loadStackRel(r uint8, offset uint16) {
+	vm.loadFromMem(r, vm.readDoubleReg(regSP)+offset)
+}
+
+// loadSignExt loads a byte from memory and sign extends it to the width of r.
+func (vm *VM) loadSignExt(r uint8, x uint16) {
+	vm.WriteReg(Register(r), uint16(int8(vm.readMem8(x))))
+}
+
+func (vm *VM) loadSignExtRegAddr(d, s uint8) {
+	vm.loadSignExt(d, vm.ReadReg(Register(s)))
+}
+
+// loadZeroExt loads a byte from memory and zero extends it to the width of r.
+func (vm *VM) loadZeroExt(r uint8, x uint16) {
+	vm.WriteReg(Register(r), uint16(vm.readMem8(x)))
+}
+
+func (vm *VM) loadZeroExtRegAddr(d, s uint8) {
+	vm.loadZeroExt(d, vm.ReadReg(Register(s)))
+}
+
 func (vm *VM) storeRegToMemory(r uint8, x uint16) {
 	switch {
 	case IsDoubleReg(Register(r)):
@@ -43,104 +75,466 @@ func (vm *VM) storeRegToRegAddr(s, d uint8) {
 	vm.storeRegToMemory(s, addr)
 }
 
+func (vm *VM) storeIndexed(r uint8, base uint16, x uint8) {
+	vm.storeRegToMemory(r, vm.indexAddr(Register(r), base, x))
+}
+
+func (vm *VM) storeIndirect(r uint8, ptr uint16) {
+	vm.storeRegToMemory(r, vm.readMem16(ptr))
+}
+
+func (vm *VM) storeStackRel(r uint8, offset uint16) {
+	vm.storeRegToMemory(r, vm.readDoubleReg(regSP)+offset)
+}
+
+// indexAddr returns the effective address of an indexed access, base plus
+// the index register in x. If the post increment bit is set, the index
+// register is incremented by the width of register r.
+func (vm *VM) indexAddr(r Register, base uint16, x uint8) uint16 {
+	ir := Register(x &^ opcodes.IndexPostInc)
+	idx := vm.ReadReg(ir)
+
+	if x&opcodes.IndexPostInc != 0 {
+		vm.WriteReg(ir, idx+uint16(regWidth(r)))
+	}
+	return base + idx
+}
+
 func (vm *VM) xferRegisters(r, s uint8) {
+	if !vm.strictWidth(Register(r), Register(s)) {
+		return
+	}
+	vm.WriteReg(Register(r), vm.ReadReg(Register(s)))
+}
+
+func (vm *VM) xferSignExt(r, s uint8) {
+	vm.WriteReg(Register(r), vm.readAnyReg2Comp(Register(s)))
+}
+
+func (vm *VM) xferZeroExt(r, s uint8) {
 	vm.WriteReg(Register(r), vm.ReadReg(Register(s)))
 }
 
+// truncate transfers the low byte of register s to register r.
+func (vm *VM) truncate(r, s uint8) {
+	vm.WriteReg(Register(r), vm.ReadReg(Register(s))&0xFF)
+}
+
+// memcpy copies n bytes from the address in s to the address in d. The copy
+// is done as if through a temporary buffer so the regions may overlap. Each
+// byte copied costs one cycle.
+func (vm *VM) memcpy(d, s, n uint8) {
+	dst := vm.ReadReg(Register(d))
+	src := vm.ReadReg(Register(s))
+	length := vm.ReadReg(Register(n))
+
+	if dst > src && dst-src < length {
+		for i := length; i > 0; i-- {
+			vm.writeMem8(dst+i-1, vm.readMem8(src+i-1))
+			vm.cycles++
+		}
+	} else {
+		for i := uint16(0); i < length; i++ {
+			vm.writeMem8(dst+i, vm.readMem8(src+i))
+			vm.cycles++
+		}
+	}
+}
+
+// memset fills n bytes starting at the address in d with the value of
+// register v. Each byte written costs one cycle.
+func (vm *VM) memset(d, v, n uint8) {
+	dst := vm.ReadReg(Register(d))
+	val := uint8(vm.ReadReg(Register(v)))
+	length := vm.ReadReg(Register(n))
+
+	for i := uint16(0); i < length; i++ {
+		vm.writeMem8(dst+i, val)
+		vm.cycles++
+	}
+}
+
 func (vm *VM) addAddr(d uint8, s uint16) {
 	dr := Register(d)
-	switch {
-	case IsDoubleReg(dr):
-		vm.writeDoubleReg(dr, vm.ReadReg(dr)+vm.readMem16(s))
-	default:
-		vm.writeSingleReg(dr, uint8(vm.ReadReg(dr))+vm.readMem8(s))
-	}
+	vm.WriteReg(dr, vm.aluAdd(dr, vm.ReadReg(dr), vm.readMemFor(dr, s)))
 }
 
 func (vm *VM) addImm(d uint8, s uint16) {
 	dr := Register(d)
-	vm.WriteReg(dr, vm.ReadReg(dr)+s)
+	vm.WriteReg(dr, vm.aluAdd(dr, vm.ReadReg(dr), s))
 }
 
 func (vm *VM) addReg(d uint8, s uint8) {
-	vm.WriteReg(Register(d), vm.ReadReg(Register(s))+vm.ReadReg(Register(d)))
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluAdd(dr, vm.ReadReg(dr), vm.ReadReg(Register(s))))
 }
 
-func (vm *VM) orAddr(d uint8, s uint16) {
+func (vm *VM) addIndexed(d uint8, base uint16, x uint8) {
+	vm.addAddr(d, vm.indexAddr(Register(d), base, x))
+}
+
+func (vm *VM) adcAddr(d uint8, s uint16) {
 	dr := Register(d)
-	switch {
-	case IsDoubleReg(dr):
-		vm.writeDoubleReg(dr, vm.ReadReg(dr)|vm.readMem16(s))
-	default:
-		vm.writeSingleReg(dr, uint8(vm.ReadReg(dr))|vm.readMem8(s))
+	vm.WriteReg(dr, vm.aluAddCarry(dr, vm.ReadReg(dr), vm.readMemFor(dr, s), vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) adcImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluAddCarry(dr, vm.ReadReg(dr), s, vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) adcReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluAddCarry(dr, vm.ReadReg(dr), vm.ReadReg(Register(s)), vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) subAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSub(dr, vm.ReadReg(dr), vm.readMemFor(dr, s)))
+}
+
+func (vm *VM) subImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSub(dr, vm.ReadReg(dr), s))
+}
+
+func (vm *VM) subReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluSub(dr, vm.ReadReg(dr), vm.ReadReg(Register(s))))
+}
+
+func (vm *VM) sbcAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSubBorrow(dr, vm.ReadReg(dr), vm.readMemFor(dr, s), vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) sbcImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSubBorrow(dr, vm.ReadReg(dr), s, vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) sbcReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluSubBorrow(dr, vm.ReadReg(dr), vm.ReadReg(Register(s)), vm.isFlagSet(FlagCarry)))
+}
+
+func (vm *VM) cmpAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.aluSub(dr, vm.ReadReg(dr), vm.readMemFor(dr, s))
+}
+
+func (vm *VM) cmpImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.aluSub(dr, vm.ReadReg(dr), s)
+}
+
+func (vm *VM) cmpReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.aluSub(dr, vm.ReadReg(dr), vm.ReadReg(Register(s)))
+}
+
+func (vm *VM) negAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSub(dr, 0, vm.readMemFor(dr, s)))
+}
+
+func (vm *VM) negImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluSub(dr, 0, s))
+}
+
+func (vm *VM) negReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
 	}
+	vm.WriteReg(dr, vm.aluSub(dr, 0, vm.ReadReg(Register(s))))
+}
+
+// INC and DEC leave the carry flag alone so they can be used as
+// loop counters in multi-byte arithmetic.
+
+func (vm *VM) inc(r uint8) {
+	rr := Register(r)
+	carry := vm.isFlagSet(FlagCarry)
+	vm.WriteReg(rr, vm.aluAdd(rr, vm.ReadReg(rr), 1))
+	vm.setFlag(FlagCarry, carry)
+}
+
+func (vm *VM) dec(r uint8) {
+	rr := Register(r)
+	carry := vm.isFlagSet(FlagCarry)
+	vm.WriteReg(rr, vm.aluSub(rr, vm.ReadReg(rr), 1))
+	vm.setFlag(FlagCarry, carry)
+}
+
+func (vm *VM) mulAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluMul(dr, vm.ReadReg(dr), vm.readMemFor(dr, s)))
+}
+
+func (vm *VM) mulImm(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluMul(dr, vm.ReadReg(dr), s))
+}
+
+func (vm *VM) mulReg(d uint8, s uint8) {
+	dr := Register(d)
+	if !vm.strictWiden(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluMul(dr, vm.ReadReg(dr), vm.ReadReg(Register(s))))
+}
+
+func (vm *VM) divAddr(d uint8, s uint16) {
+	vm.divide(Register(d), vm.readMemFor(Register(d), s), false)
+}
+
+func (vm *VM) divImm(d uint8, s uint16) {
+	vm.divide(Register(d), s, false)
+}
+
+func (vm *VM) divReg(d uint8, s uint8) {
+	if !vm.strictWiden(Register(d), Register(s)) {
+		return
+	}
+	vm.divide(Register(d), vm.ReadReg(Register(s)), false)
+}
+
+func (vm *VM) modAddr(d uint8, s uint16) {
+	vm.divide(Register(d), vm.readMemFor(Register(d), s), true)
+}
+
+func (vm *VM) modImm(d uint8, s uint16) {
+	vm.divide(Register(d), s, true)
+}
+
+func (vm *VM) modReg(d uint8, s uint8) {
+	if !vm.strictWiden(Register(d), Register(s)) {
+		return
+	}
+	vm.divide(Register(d), vm.ReadReg(Register(s)), true)
+}
+
+// mulWide multiplies single registers a and b and stores the 16-bit product
+// in double register d.
+func (vm *VM) mulWide(d, a, b uint8) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluMul(dr, vm.ReadReg(Register(a)), vm.ReadReg(Register(b))))
+}
+
+// divideWide divides double register a by single register b and stores
+// either the quotient or the remainder in single register d. C and V are set
+// when the quotient doesn't fit in d. Dividing by zero faults the machine.
+func (vm *VM) divideWide(d, a, b uint8, remainder bool) {
+	dr := Register(d)
+	s := vm.ReadReg(Register(b))
+	if s == 0 {
+		vm.fault(FaultDivideByZero)
+		return
+	}
+
+	n := vm.ReadReg(Register(a))
+	res := n / s
+	if remainder {
+		res = n % s
+	}
+
+	vm.WriteReg(dr, vm.aluLogic(dr, res))
+	vm.setFlag(FlagCarry, res > 0xFF)
+	vm.setFlag(FlagOverflow, res > 0xFF)
+}
+
+// divide divides register dr by s and stores either the quotient
+// or the remainder in dr. Dividing by zero faults the machine.
+func (vm *VM) divide(dr Register, s uint16, remainder bool) {
+	if s == 0 {
+		vm.fault(FaultDivideByZero)
+		return
+	}
+
+	a := vm.ReadReg(dr)
+	if remainder {
+		vm.WriteReg(dr, vm.aluLogic(dr, a%s))
+	} else {
+		vm.WriteReg(dr, vm.aluLogic(dr, a/s))
+	}
+}
+
+func (vm *VM) orAddr(d uint8, s uint16) {
+	dr := Register(d)
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)|vm.readMemFor(dr, s)))
 }
 
 func (vm *VM) orImm(d uint8, s uint16) {
 	dr := Register(d)
-	vm.WriteReg(dr, vm.ReadReg(dr)|s)
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)|s))
 }
 
 func (vm *VM) orReg(d uint8, s uint8) {
-	vm.WriteReg(Register(d), vm.ReadReg(Register(s))|vm.ReadReg(Register(d)))
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)|vm.ReadReg(Register(s))))
+}
+
+func (vm *VM) orIndexed(d uint8, base uint16, x uint8) {
+	vm.orAddr(d, vm.indexAddr(Register(d), base, x))
 }
 
 func (vm *VM) andAddr(d uint8, s uint16) {
 	dr := Register(d)
-	switch {
-	case IsDoubleReg(dr):
-		vm.writeDoubleReg(dr, vm.ReadReg(dr)&vm.readMem16(s))
-	default:
-		vm.writeSingleReg(dr, uint8(vm.ReadReg(dr))&vm.readMem8(s))
-	}
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)&vm.readMemFor(dr, s)))
 }
 
 func (vm *VM) andImm(d uint8, s uint16) {
 	dr := Register(d)
-	vm.WriteReg(dr, vm.ReadReg(dr)&s)
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)&s))
 }
 
 func (vm *VM) andReg(d uint8, s uint8) {
-	vm.WriteReg(Register(d), vm.ReadReg(Register(s))&vm.ReadReg(Register(d)))
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)&vm.ReadReg(Register(s))))
+}
+
+func (vm *VM) andIndexed(d uint8, base uint16, x uint8) {
+	vm.andAddr(d, vm.indexAddr(Register(d), base, x))
 }
 
 func (vm *VM) xorAddr(d uint8, s uint16) {
 	dr := Register(d)
-	switch {
-	case IsDoubleReg(dr):
-		vm.writeDoubleReg(dr, vm.ReadReg(dr)^vm.readMem16(s))
-	default:
-		vm.writeSingleReg(dr, uint8(vm.ReadReg(dr))^vm.readMem8(s))
-	}
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)^vm.readMemFor(dr, s)))
 }
 
 func (vm *VM) xorImm(d uint8, s uint16) {
 	dr := Register(d)
-	vm.WriteReg(dr, vm.ReadReg(dr)^s)
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)^s))
 }
 
 func (vm *VM) xorReg(d uint8, s uint8) {
-	vm.WriteReg(Register(d), vm.ReadReg(Register(s))^vm.ReadReg(Register(d)))
+	dr := Register(d)
+	if !vm.strictWidth(dr, Register(s)) {
+		return
+	}
+	vm.WriteReg(dr, vm.aluLogic(dr, vm.ReadReg(dr)^vm.ReadReg(Register(s))))
+}
+
+func (vm *VM) xorIndexed(d uint8, base uint16, x uint8) {
+	vm.xorAddr(d, vm.indexAddr(Register(d), base, x))
 }
 
 func (vm *VM) rotrRegister(r, x uint8) {
 	rr := Register(r)
+	var v uint16
 	switch {
 	case IsDoubleReg(rr):
-		vm.writeDoubleReg(rr, bits.RotateLeft16(vm.readDoubleReg(rr), int(-x)))
+		v = bits.RotateLeft16(vm.readDoubleReg(rr), int(-x))
 	default:
-		vm.writeSingleReg(rr, bits.RotateLeft8(vm.readSingleReg(rr), int(-x)))
+		v = uint16(bits.RotateLeft8(vm.readSingleReg(rr), int(-x)))
 	}
+	vm.WriteReg(rr, vm.aluLogic(rr, v))
+
+	// The last bit rotated out of the bottom is now the high bit
+	_, sign := widthMask(rr)
+	vm.setFlag(FlagCarry, x != 0 && v&sign != 0)
 }
 
 func (vm *VM) rotlRegister(r, x uint8) {
 	rr := Register(r)
+	var v uint16
 	switch {
 	case IsDoubleReg(rr):
-		vm.writeDoubleReg(rr, bits.RotateLeft16(vm.readDoubleReg(rr), int(x)))
+		v = bits.RotateLeft16(vm.readDoubleReg(rr), int(x))
 	default:
-		vm.writeSingleReg(rr, bits.RotateLeft8(vm.readSingleReg(rr), int(x)))
+		v = uint16(bits.RotateLeft8(vm.readSingleReg(rr), int(x)))
 	}
+	vm.WriteReg(rr, vm.aluLogic(rr, v))
+
+	// The last bit rotated out of the top is now the low bit
+	vm.setFlag(FlagCarry, x != 0 && v&1 != 0)
+}
+
+func (vm *VM) shlImm(r, x uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), uint16(x), shiftLeft))
+}
+
+func (vm *VM) shlReg(r, s uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), vm.ReadReg(Register(s)), shiftLeft))
+}
+
+func (vm *VM) shrImm(r, x uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), uint16(x), shiftRight))
+}
+
+func (vm *VM) shrReg(r, s uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), vm.ReadReg(Register(s)), shiftRight))
+}
+
+func (vm *VM) asrImm(r, x uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), uint16(x), shiftArith))
+}
+
+func (vm *VM) asrReg(r, s uint8) {
+	rr := Register(r)
+	vm.WriteReg(rr, vm.aluShift(rr, vm.ReadReg(rr), vm.ReadReg(Register(s)), shiftArith))
+}
+
+// The bit instructions set the zero flag if the bit was clear before
+// the instruction.
+
+func (vm *VM) bsetAddr(a uint16, n uint8) {
+	v := vm.readMem8(a)
+	vm.writeMem8(a, v|uint8(vm.aluBit(uint16(v), 8, n)))
+}
+
+func (vm *VM) bsetReg(r, n uint8) {
+	rr := Register(r)
+	v := vm.ReadReg(rr)
+	vm.WriteReg(rr, v|vm.aluBit(v, regBits(rr), n))
+}
+
+func (vm *VM) bclrAddr(a uint16, n uint8) {
+	v := vm.readMem8(a)
+	vm.writeMem8(a, v&^uint8(vm.aluBit(uint16(v), 8, n)))
+}
+
+func (vm *VM) bclrReg(r, n uint8) {
+	rr := Register(r)
+	v := vm.ReadReg(rr)
+	vm.WriteReg(rr, v&^vm.aluBit(v, regBits(rr), n))
+}
+
+func (vm *VM) btstAddr(a uint16, n uint8) {
+	vm.aluBit(uint16(vm.readMem8(a)), 8, n)
+}
+
+func (vm *VM) btstReg(r, n uint8) {
+	rr := Register(r)
+	vm.aluBit(vm.ReadReg(rr), regBits(rr), n)
 }
 
 func (vm *VM) jumpEq(r uint8, d uint16) {
@@ -149,20 +543,58 @@ func (vm *VM) jumpEq(r uint8, d uint16) {
 	}
 }
 
+// loop decrements a register and jumps if it's not zero. Flags aren't changed.
+func (vm *VM) loop(r uint8, d uint16) {
+	rr := Register(r)
+	v := vm.ReadReg(rr) - 1
+	vm.WriteReg(rr, v)
+
+	mask, _ := widthMask(rr)
+	if v&mask != 0 {
+		vm.pc = d
+	}
+}
+
+func (vm *VM) jumpFlag(f Flag, set bool, d uint16) {
+	if vm.isFlagSet(f) == set {
+		vm.pc = d
+	}
+}
+
 func (vm *VM) jumpAbs(d uint16) {
 	vm.pc = d
 }
 
+func (vm *VM) jumpIndirect(ptr uint16) {
+	vm.pc = vm.readMem16(ptr)
+}
+
+// Branch displacements are relative to the address of the next instruction
+
+func (vm *VM) branch(d uint16) {
+	vm.pc += d
+}
+
+func (vm *VM) branchFlag(f Flag, set bool, d uint16) {
+	if vm.isFlagSet(f) == set {
+		vm.pc += d
+	}
+}
+
+func (vm *VM) branchSub(d uint16) {
+	vm.calla(vm.pc + d)
+}
+
 func (vm *VM) loadSPAddr(d uint16) {
-	vm.sp = vm.readMem16(d)
+	vm.writeDoubleReg(regSP, vm.readMem16(d))
 }
 
 func (vm *VM) loadSPImm(d uint16) {
-	vm.sp = d
+	vm.writeDoubleReg(regSP, d)
 }
 
 func (vm *VM) loadSPReg(d uint8) {
-	vm.sp = vm.ReadReg(Register(d))
+	vm.writeDoubleReg(regSP, vm.ReadReg(Register(d)))
 }
 
 func (vm *VM) push(r uint8) {
@@ -171,8 +603,7 @@ func (vm *VM) push(r uint8) {
 	case IsDoubleReg(rr):
 		vm.push16(vm.readDoubleReg(rr))
 	default:
-		vm.sp--
-		vm.writeMem8(vm.sp, vm.readSingleReg(rr))
+		vm.push8(vm.readSingleReg(rr))
 	}
 }
 
@@ -182,22 +613,86 @@ func (vm *VM) pop(r uint8) {
 	case IsDoubleReg(rr):
 		vm.writeDoubleReg(rr, vm.pop16())
 	default:
-		vm.writeSingleReg(rr, vm.readMem8(vm.sp))
-		vm.sp++
+		vm.writeSingleReg(rr, vm.pop8())
+	}
+}
+
+// pushAll pushes registers 0 - 9 followed by the flags.
+func (vm *VM) pushAll() {
+	for r := Register0; r <= Register9; r++ {
+		vm.push(uint8(r))
+	}
+	vm.push8(vm.flags)
+}
+
+// popAll restores the flags and registers pushed by pushAll.
+func (vm *VM) popAll() {
+	vm.flags = vm.pop8()
+	for r := int(Register9); r >= int(Register0); r-- {
+		vm.pop(uint8(r))
+	}
+}
+
+func (vm *VM) push8(v uint8) {
+	if !vm.checkPush(1) {
+		return
+	}
+	vm.sp--
+	vm.touchRegWrite(RegisterSP)
+	vm.writeMem8(vm.sp, v)
+}
+
+func (vm *VM) pop8() uint8 {
+	if !vm.checkPop(1) {
+		return 0
 	}
+	v := vm.readMem8(vm.sp)
+	vm.sp++
+	vm.touchRegWrite(RegisterSP)
+	return v
 }
 
 func (vm *VM) push16(v uint16) {
+	if !vm.checkPush(2) {
+		return
+	}
 	vm.sp -= 2
+	vm.touchRegWrite(RegisterSP)
 	vm.writeMem16(vm.sp, v)
 }
 
 func (vm *VM) pop16() uint16 {
+	if !vm.checkPop(2) {
+		return 0
+	}
 	v := vm.readMem16(vm.sp)
 	vm.sp += 2
+	vm.touchRegWrite(RegisterSP)
 	return v
 }
 
+// checkPush faults the machine if pushing n bytes would wrap the stack
+// pointer past address 0. It returns if the push should continue.
+func (vm *VM) checkPush(n uint32) bool {
+	vm.touchRegRead(RegisterSP)
+	if uint32(vm.sp) < n {
+		vm.fault(FaultStackOverflow)
+		return false
+	}
+	return true
+}
+
+// checkPop faults the machine if popping n bytes would wrap the stack
+// pointer past the top of memory. It returns if the pop should continue.
+func (vm *VM) checkPop(n uint32) bool {
+	vm.touchRegRead(RegisterSP)
+	if uint32(vm.sp)+n > numOfMemoryCells {
+		vm.fault(FaultStackUnderflow)
+		return false
+	}
+	return true
+}
+
 func (vm *VM) calla(pc uint16) {
 	vm.push16(vm.pc)
 	vm.pc = pc
@@ -208,6 +703,28 @@ func (vm *VM) callr(r uint8) {
 	vm.pc = vm.ReadReg(Register(r))
 }
 
+func (vm *VM) callIndirect(ptr uint16) {
+	vm.calla(vm.readMem16(ptr))
+}
+
+func (vm *VM) callEq(r uint8, d uint16) {
+	if vm.ReadReg(Register(r)) == uint16(vm.readSingleReg(0)) {
+		vm.calla(d)
+	}
+}
+
+func (vm *VM) callFlag(f Flag, set bool, d uint16) {
+	if vm.isFlagSet(f) == set {
+		vm.calla(d)
+	}
+}
+
 func (vm *VM) rtn() {
 	vm.pc = vm.pop16()
 }
+
+func (vm *VM) rtnFlag(f Flag, set bool) {
+	if vm.isFlagSet(f) == set {
+		vm.rtn()
+	}
+}
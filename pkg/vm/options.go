@@ -0,0 +1,68 @@
+package vm
+
+import "io"
+
+// Option configures a machine created with New.
+type Option func(*VM)
+
+// WithPrintState writes the machine state to the output before every
+// instruction.
+func WithPrintState(on bool) Option {
+	return func(vm *VM) {
+		vm.printState = on
+	}
+}
+
+// WithOutput sets where the machine writes the printer and state output. By
+// default the output is discarded.
+func WithOutput(w io.Writer) Option {
+	return func(vm *VM) {
+		vm.out = w
+	}
+}
+
+// WithPrinterOutput sets where printed characters are written, instead of
+// the output writer. It can't be used with WithLegacyPrinter, New returns
+// ErrPrinterOptions if both are given.
+func WithPrinterOutput(w io.Writer) Option {
+	return func(vm *VM) {
+		vm.printerOut = w
+	}
+}
+
+// WithLegacyPrinter collects printed characters until the machine halts and
+// then writes them to the output, in a "Printer:" block when the state is
+// printed. By default characters are written as they're printed. It can't
+// be used with WithPrinterOutput, New returns ErrPrinterOptions if both are
+// given.
+func WithLegacyPrinter(on bool) Option {
+	return func(vm *VM) {
+		vm.legacy = on
+	}
+}
+
+// WithStrict turns on strict mode. In strict mode, register to register
+// instructions with mismatched register widths fault instead of truncating
+// or zero extending the value.
+func WithStrict(on bool) Option {
+	return func(vm *VM) {
+		vm.strict = on
+	}
+}
+
+// WithProtectedRange makes the memory addresses start through end read only.
+// A write to them by the program faults the machine. Code is loaded before
+// protection applies so a program can protect itself.
+func WithProtectedRange(start, end uint16) Option {
+	return func(vm *VM) {
+		vm.protected = append(vm.protected, memRange{start: start, end: end})
+	}
+}
+
+// WithDevice attaches a memory mapped device to the machine bus. Devices are
+// checked in the order they're attached, after the printer.
+func WithDevice(d Device) Option {
+	return func(vm *VM) {
+		vm.devices = append(vm.devices, d)
+	}
+}
@@ -24,6 +24,9 @@ func (vm *VM) PrintState() {
 	vm.writeString(formatHex16(vm.pc - 1))
 	vm.writeString("\nStack Pointer  = ")
 	vm.writeString(formatHex16(vm.sp))
+	vm.writeString("\nFlags  = ")
+	vm.writeString(formatFlags(vm.flags))
+	vm.writeString(fmt.Sprintf("\nCycles  = %d", vm.cycles))
 	vm.writeString("\n\n")
 }
 
@@ -53,7 +56,7 @@ func (vm *VM) writeString(s string) {
 }
 
 func (vm *VM) writePrinter() {
-	vm.output.Write(vm.printer.Bytes())
+	vm.output.Write(vm.printer.buf.Bytes())
 }
 
 // Format a uint8 as a hex number with leading zeros
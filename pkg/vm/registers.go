@@ -18,10 +18,12 @@ const (
 	RegisterB
 	RegisterC
 	RegisterD
+	RegisterSP
 )
 
+// IsDoubleReg returns if r is a 16-bit register, either A - D or the stack pointer.
 func IsDoubleReg(r Register) bool {
-	return r >= regA && r <= regD
+	return r >= regA && r <= regD || r == regSP
 }
 
 func regWidth(r Register) uint8 {
@@ -33,29 +35,66 @@ func regWidth(r Register) uint8 {
 	return 0
 }
 
-func checkRegWidth(regs ...Register) {
+// regBits returns the width of register r in bits.
+func regBits(r Register) uint8 {
+	if IsDoubleReg(r) {
+		return 16
+	}
+	return 8
+}
+
+// checkRegWidth returns if all registers are the same width.
+func checkRegWidth(regs ...Register) bool {
 	w := regWidth(regs[0])
 	for _, r := range regs {
 		if regWidth(r) != w {
-			panic("register widths don't match")
+			return false
 		}
 	}
+	return true
+}
+
+// strictWidth faults the machine in strict mode if the registers aren't the
+// same width. It returns if the instruction should continue.
+func (vm *VM) strictWidth(regs ...Register) bool {
+	if !vm.strict || checkRegWidth(regs...) {
+		return true
+	}
+	vm.fault(FaultRegisterWidth)
+	return false
+}
+
+// strictWiden faults the machine in strict mode if the source register is
+// wider than the destination. It returns if the instruction should continue.
+func (vm *VM) strictWiden(d, s Register) bool {
+	if !vm.strict || regWidth(s) <= regWidth(d) {
+		return true
+	}
+	vm.fault(FaultRegisterWidth)
+	return false
 }
 
 // width can be 1 or 2
 // return value will be 8 or 16-bit depending on width
 func (vm *VM) ReadMem(addr uint16, width int) uint16 {
+	vm.touchMemRead(addr, width)
+
 	switch width {
 	case 1:
-		return uint16(vm.memory[addr])
+		return uint16(vm.busRead(addr))
 	case 2:
-		b1 := uint16(vm.memory[addr])
-		b2 := uint16(vm.memory[addr+1])
+		b1 := uint16(vm.busRead(addr))
+		b2 := uint16(vm.busRead(addr + 1))
 		return (b1 << 8) + b2
 	}
 	return 0
 }
 
+// readMemFor reads a value from memory the width of register r.
+func (vm *VM) readMemFor(r Register, addr uint16) uint16 {
+	return vm.ReadMem(addr, int(regWidth(r)))
+}
+
 func (vm *VM) readMem8(addr uint16) uint8 {
 	return uint8(vm.ReadMem(addr, 1))
 }
@@ -66,13 +105,31 @@ func (vm *VM) readMem16(addr uint16) uint16 {
 
 // width can be 1 or 2
 func (vm *VM) WriteMem(addr uint16, width int, val uint16) {
+	for i := 0; i < width; i++ {
+		if vm.isProtected(addr + uint16(i)) {
+			vm.faultAddr(FaultProtectedWrite, addr+uint16(i))
+			return
+		}
+	}
+
+	vm.touchMemWrite(addr, width)
+
 	switch width {
 	case 1:
-		vm.memory[addr] = uint8(val)
+		vm.busWrite(addr, uint8(val))
 	case 2:
-		vm.memory[addr] = uint8(val >> 8)
-		vm.memory[addr+1] = uint8(val)
+		vm.busWrite(addr, uint8(val>>8))
+		vm.busWrite(addr+1, uint8(val))
+	}
+}
+
+func (vm *VM) isProtected(addr uint16) bool {
+	for _, r := range vm.protected {
+		if addr >= r.start && addr <= r.end {
+			return true
+		}
 	}
+	return false
 }
 
 func (vm *VM) writeMem8(addr uint16, val uint8) {
@@ -111,14 +168,26 @@ func (vm *VM) readAnyReg2Comp(r Register) uint16 {
 }
 
 func (vm *VM) writeSingleReg(r Register, v uint8) {
+	if r > Register9 {
+		vm.fault(FaultInvalidRegister)
+		return
+	}
+	vm.touchRegWrite(r)
 	vm.registers[r] = v
 }
 
 func (vm *VM) readSingleReg(r Register) uint8 {
+	if r > Register9 {
+		vm.fault(FaultInvalidRegister)
+		return 0
+	}
+	vm.touchRegRead(r)
 	return vm.registers[r]
 }
 
 func (vm *VM) writeDoubleReg(r Register, v uint16) {
+	vm.touchRegWrite(r)
+
 	switch r {
 	case regA:
 		vm.registers[2] = uint8(v >> 8)
@@ -132,10 +201,16 @@ func (vm *VM) writeDoubleReg(r Register, v uint16) {
 	case regD:
 		vm.registers[8] = uint8(v >> 8)
 		vm.registers[9] = uint8(v)
+	case regSP:
+		vm.sp = v
+	default:
+		vm.fault(FaultInvalidRegister)
 	}
 }
 
 func (vm *VM) readDoubleReg(r Register) uint16 {
+	vm.touchRegRead(r)
+
 	switch r {
 	case regA:
 		return (uint16(vm.registers[2]) << 8) + uint16(vm.registers[3])
@@ -145,6 +220,9 @@ func (vm *VM) readDoubleReg(r Register) uint16 {
 		return (uint16(vm.registers[6]) << 8) + uint16(vm.registers[7])
 	case regD:
 		return (uint16(vm.registers[8]) << 8) + uint16(vm.registers[9])
+	case regSP:
+		return vm.sp
 	}
+	vm.fault(FaultInvalidRegister)
 	return 0
 }
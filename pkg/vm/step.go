@@ -0,0 +1,68 @@
+package vm
+
+// StepInfo describes an instruction executed by Step.
+type StepInfo struct {
+	Addr     uint16 // Address of the instruction
+	Opcode   byte
+	Mnemonic string // Empty for an invalid opcode
+	Operands []byte // Operand bytes following the opcode
+
+	RegsRead    []Register
+	RegsWritten []Register
+	MemRead     []uint16 // Memory addresses read, not including the instruction fetch
+	MemWritten  []uint16
+
+	Flags        uint8 // Value of the flags after the instruction
+	FlagsChanged Flag  // Flags whose value the instruction changed
+
+	// "IRQ" or "NMI" if an interrupt was taken before the instruction. The
+	// stack writes and vector read of taking it are included above.
+	Interrupt string
+	Waiting   bool // The machine is waiting for an interrupt and didn't execute anything
+
+	Cycles uint64 // Cycles taken by the instruction
+	Halted bool
+}
+
+func (info *StepInfo) setFlags(before, after uint8) {
+	info.Flags = after
+	info.FlagsChanged = Flag(before ^ after)
+}
+
+func (vm *VM) touchRegRead(r Register) {
+	if vm.step != nil {
+		vm.step.RegsRead = appendReg(vm.step.RegsRead, r)
+	}
+}
+
+func (vm *VM) touchRegWrite(r Register) {
+	if vm.step != nil {
+		vm.step.RegsWritten = appendReg(vm.step.RegsWritten, r)
+	}
+}
+
+func (vm *VM) touchMemRead(addr uint16, width int) {
+	if vm.step != nil {
+		for i := 0; i < width; i++ {
+			vm.step.MemRead = append(vm.step.MemRead, addr+uint16(i))
+		}
+	}
+}
+
+func (vm *VM) touchMemWrite(addr uint16, width int) {
+	if vm.step != nil {
+		for i := 0; i < width; i++ {
+			vm.step.MemWritten = append(vm.step.MemWritten, addr+uint16(i))
+		}
+	}
+}
+
+// appendReg appends r to regs if it isn't already in the list.
+func appendReg(regs []Register, r Register) []Register {
+	for _, reg := range regs {
+		if reg == r {
+			return regs
+		}
+	}
+	return append(regs, r)
+}
@@ -0,0 +1,150 @@
+package vm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/lfkeitel/asml-sim/pkg/opcodes"
+)
+
+// TestOpcodeNames checks every opcode the machine executes has a mnemonic,
+// and every opcode with a mnemonic is executed.
+func TestOpcodeNames(t *testing.T) {
+	for op := 0; op < 256; op++ {
+		sim := newCodeVM(t, []byte{byte(op), 0, 0, 0, 0})
+		_, err := sim.Step()
+		f, _ := err.(*Fault)
+		invalid := f != nil && f.Kind == FaultInvalidOpcode
+
+		name, named := opcodes.Name(byte(op))
+		if !invalid && !named {
+			t.Errorf("opcode 0x%02X is executed but has no name", op)
+		}
+		if invalid && named {
+			t.Errorf("opcode 0x%02X is named %s but isn't executed", op, name)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	sim := newTestVM(t, `
+	ORG 0xFFF8
+	FDB on_irq
+
+	ORG 0xFFFE
+	FDB main
+
+	ORG 0x0000
+:main
+	LDSP #0x0100
+	LOAD %1 #5
+	STR %1 0x0200
+	PUSH %A
+	LOAD %B 0x0200
+	CMP %1 #5
+	CALL sub
+	EI
+	HALT
+
+:sub
+	RTN
+
+:on_irq
+	STR %0 0xFF00
+	RTI
+`, WithOutput(&bytes.Buffer{}), WithDevice(&irqDevice{raised: true}))
+
+	tests := []StepInfo{
+		{
+			Addr: 0x0000, Opcode: opcodes.LDSPI, Mnemonic: "LDSPI",
+			Operands:    []byte{0x01, 0x00},
+			RegsWritten: []Register{RegisterSP}, Cycles: 1,
+		},
+		{
+			Addr: 0x0003, Opcode: opcodes.LOADI, Mnemonic: "LOADI",
+			Operands:    []byte{0x01, 0x00, 0x05},
+			RegsWritten: []Register{Register1}, Cycles: 1,
+		},
+		{
+			Addr: 0x0007, Opcode: opcodes.STRA, Mnemonic: "STRA",
+			Operands:   []byte{0x01, 0x02, 0x00},
+			RegsRead:   []Register{Register1},
+			MemWritten: []uint16{0x0200}, Cycles: 1,
+		},
+		{
+			Addr: 0x000B, Opcode: opcodes.PUSH, Mnemonic: "PUSH",
+			Operands:    []byte{0x0A},
+			RegsRead:    []Register{RegisterA, RegisterSP},
+			RegsWritten: []Register{RegisterSP},
+			MemWritten:  []uint16{0x00FE, 0x00FF}, Cycles: 1,
+		},
+		{
+			Addr: 0x000D, Opcode: opcodes.LOADA, Mnemonic: "LOADA",
+			Operands:    []byte{0x0B, 0x02, 0x00},
+			RegsWritten: []Register{RegisterB},
+			MemRead:     []uint16{0x0200, 0x0201}, Cycles: 1,
+		},
+		{
+			Addr: 0x0011, Opcode: opcodes.CMPI, Mnemonic: "CMPI",
+			Operands: []byte{0x01, 0x00, 0x05},
+			RegsRead: []Register{Register1},
+			Flags:    uint8(FlagZero), FlagsChanged: FlagZero, Cycles: 1,
+		},
+		{
+			Addr: 0x0015, Opcode: opcodes.CALLA, Mnemonic: "CALLA",
+			Operands:    []byte{0x00, 0x1A},
+			RegsRead:    []Register{RegisterSP},
+			RegsWritten: []Register{RegisterSP},
+			MemWritten:  []uint16{0x00FC, 0x00FD},
+			Flags:       uint8(FlagZero), Cycles: 1,
+		},
+		{
+			Addr: 0x001A, Opcode: opcodes.RTN, Mnemonic: "RTN",
+			RegsRead:    []Register{RegisterSP},
+			RegsWritten: []Register{RegisterSP},
+			MemRead:     []uint16{0x00FC, 0x00FD},
+			Flags:       uint8(FlagZero), Cycles: 1,
+		},
+		{
+			Addr: 0x0018, Opcode: opcodes.EI, Mnemonic: "EI",
+			Flags: uint8(FlagZero | FlagInterrupt), FlagsChanged: FlagInterrupt, Cycles: 1,
+		},
+		{
+			// Taking the IRQ pushes the program counter and flags, and
+			// reads the vector, before the handler's first instruction
+			Addr: 0x001B, Opcode: opcodes.STRA, Mnemonic: "STRA", Interrupt: "IRQ",
+			Operands:    []byte{0x00, 0xFF, 0x00},
+			RegsRead:    []Register{RegisterSP, Register0},
+			RegsWritten: []Register{RegisterSP},
+			MemRead:     []uint16{IRQVector, IRQVector + 1},
+			MemWritten:  []uint16{0x00FC, 0x00FD, 0x00FB, 0xFF00},
+			Flags:       uint8(FlagZero), FlagsChanged: FlagInterrupt, Cycles: 1,
+		},
+		{
+			Addr: 0x001F, Opcode: opcodes.RTI, Mnemonic: "RTI",
+			RegsRead:    []Register{RegisterSP},
+			RegsWritten: []Register{RegisterSP},
+			MemRead:     []uint16{0x00FB, 0x00FC, 0x00FD},
+			Flags:       uint8(FlagZero | FlagInterrupt), FlagsChanged: FlagInterrupt, Cycles: 1,
+		},
+		{
+			Addr: 0x0019, Opcode: opcodes.HALT, Mnemonic: "HALT",
+			Flags: uint8(FlagZero | FlagInterrupt), Cycles: 1, Halted: true,
+		},
+	}
+
+	for _, expected := range tests {
+		info, err := sim.Step()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", expected.Mnemonic, err)
+		}
+		if !reflect.DeepEqual(*info, expected) {
+			t.Errorf("%s:\ngot      %+v\nexpected %+v", expected.Mnemonic, *info, expected)
+		}
+	}
+
+	if _, err := sim.Step(); err != ErrHalted {
+		t.Errorf("stepping a halted machine returned %v, expected ErrHalted", err)
+	}
+}
@@ -0,0 +1,123 @@
+package vm
+
+// Timer addresses
+const (
+	TimerReloadAddr  = 0xFFE0 // 2 bytes, the value the counter starts from
+	TimerCounterAddr = 0xFFE2 // 2 bytes, read only
+	TimerControlAddr = 0xFFE4
+	TimerStatusAddr  = 0xFFE5
+)
+
+// Timer control bits
+const (
+	TimerEnable   = 1 << iota // Count down, starting from the reload value
+	TimerPeriodic             // Restart from the reload value on expiry instead of stopping
+	TimerIRQ                  // Request an interrupt while the timer is expired
+)
+
+// TimerExpired is set in the status register when the counter reaches 0.
+// Writing to the status register clears it.
+const TimerExpired = 1
+
+// Timer is a programmable interval timer. While enabled, the counter
+// counts down by one every cycle, starting with the instruction after the
+// one that enabled it. When it reaches 0 the timer expires, and then either
+// stops or restarts from the reload value. A reload value of 0 counts 65536
+// cycles, the counter reads as 0xFFFF until it's counted down below that.
+type Timer struct {
+	reload   uint16
+	counter  uint32
+	control  uint8
+	status   uint8
+	starting bool // Enabled by the current instruction, don't count its cycles
+}
+
+// NewTimer creates a stopped timer.
+func NewTimer() *Timer {
+	return &Timer{}
+}
+
+func (t *Timer) Range() (start, end uint16) { return TimerReloadAddr, TimerStatusAddr }
+
+func (t *Timer) Read(addr uint16) uint8 {
+	switch addr {
+	case TimerReloadAddr:
+		return uint8(t.reload >> 8)
+	case TimerReloadAddr + 1:
+		return uint8(t.reload)
+	case TimerCounterAddr:
+		return uint8(t.count() >> 8)
+	case TimerCounterAddr + 1:
+		return uint8(t.count())
+	case TimerControlAddr:
+		return t.control
+	case TimerStatusAddr:
+		return t.status
+	}
+	return 0
+}
+
+func (t *Timer) Write(addr uint16, v uint8) {
+	switch addr {
+	case TimerReloadAddr:
+		t.reload = uint16(v)<<8 | t.reload&0xFF
+	case TimerReloadAddr + 1:
+		t.reload = t.reload&0xFF00 | uint16(v)
+	case TimerControlAddr:
+		if t.control&TimerEnable == 0 && v&TimerEnable != 0 {
+			t.load()
+			t.starting = true
+		}
+		t.control = v
+	case TimerStatusAddr:
+		t.status = 0
+	}
+}
+
+// Tick counts the counter down by the number of cycles. The cycles of the
+// instruction that enabled the timer aren't counted.
+func (t *Timer) Tick(cycles uint64) {
+	if t.starting {
+		t.starting = false
+		return
+	}
+	for t.control&TimerEnable != 0 && cycles > 0 {
+		if uint64(t.counter) > cycles {
+			t.counter -= uint32(cycles)
+			return
+		}
+		cycles -= uint64(t.counter)
+		t.expire()
+	}
+}
+
+// IRQ requests an interrupt while the timer is expired, if interrupts are
+// turned on.
+func (t *Timer) IRQ() bool {
+	return t.control&TimerIRQ != 0 && t.status&TimerExpired != 0
+}
+
+// count returns the counter value the program reads.
+func (t *Timer) count() uint16 {
+	if t.counter > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(t.counter)
+}
+
+func (t *Timer) load() {
+	t.counter = uint32(t.reload)
+	if t.counter == 0 {
+		t.counter = 0x10000
+	}
+}
+
+func (t *Timer) expire() {
+	t.status |= TimerExpired
+	if t.control&TimerPeriodic != 0 {
+		t.load()
+	} else {
+		t.counter = 0
+		t.control &^= TimerEnable
+	}
+}
@@ -0,0 +1,145 @@
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// startTimer sets the reload value and writes control to a new timer. The
+// first tick is the instruction that wrote control, so it isn't counted.
+func startTimer(reload uint16, control uint8) *Timer {
+	t := NewTimer()
+	t.Write(TimerReloadAddr, uint8(reload>>8))
+	t.Write(TimerReloadAddr+1, uint8(reload))
+	t.Write(TimerControlAddr, control)
+	t.Tick(10)
+	return t
+}
+
+func readCounter(t *Timer) uint16 {
+	return uint16(t.Read(TimerCounterAddr))<<8 | uint16(t.Read(TimerCounterAddr+1))
+}
+
+func TestTimerOneShot(t *testing.T) {
+	timer := startTimer(5, TimerEnable)
+	if c := readCounter(timer); c != 5 {
+		t.Fatalf("counter %d after the enabling instruction, expected 5", c)
+	}
+
+	timer.Tick(4)
+	if c := readCounter(timer); c != 1 {
+		t.Fatalf("counter %d, expected 1", c)
+	}
+	if timer.Read(TimerStatusAddr) != 0 {
+		t.Fatal("timer expired early")
+	}
+
+	timer.Tick(3)
+	if timer.Read(TimerStatusAddr) != TimerExpired {
+		t.Fatal("timer didn't expire")
+	}
+	if timer.Read(TimerControlAddr)&TimerEnable != 0 {
+		t.Error("one shot timer still enabled")
+	}
+	if c := readCounter(timer); c != 0 {
+		t.Errorf("counter %d after expiring, expected 0", c)
+	}
+
+	timer.Write(TimerStatusAddr, 0)
+	if timer.Read(TimerStatusAddr) != 0 {
+		t.Error("writing the status didn't clear it")
+	}
+}
+
+func TestTimerPeriodic(t *testing.T) {
+	timer := startTimer(4, TimerEnable|TimerPeriodic)
+
+	// Expires at 4 and 8, then counts 2 more
+	timer.Tick(10)
+	if timer.Read(TimerStatusAddr) != TimerExpired {
+		t.Fatal("timer didn't expire")
+	}
+	if timer.Read(TimerControlAddr)&TimerEnable == 0 {
+		t.Error("periodic timer stopped")
+	}
+	if c := readCounter(timer); c != 2 {
+		t.Errorf("counter %d, expected 2", c)
+	}
+}
+
+func TestTimerReloadZero(t *testing.T) {
+	timer := startTimer(0, TimerEnable)
+	if c := readCounter(timer); c != 0xFFFF {
+		t.Fatalf("counter %#x counting 65536, expected 0xffff", c)
+	}
+
+	timer.Tick(1)
+	if c := readCounter(timer); c != 0xFFFF {
+		t.Fatalf("counter %#x, expected 0xffff", c)
+	}
+
+	timer.Tick(0xFFFE)
+	if c := readCounter(timer); c != 1 || timer.Read(TimerStatusAddr) != 0 {
+		t.Fatalf("counter %#x, expected 1 and not expired", c)
+	}
+
+	timer.Tick(1)
+	if timer.Read(TimerStatusAddr) != TimerExpired {
+		t.Error("timer didn't expire after 65536 cycles")
+	}
+}
+
+func TestTimerStartsNextInstruction(t *testing.T) {
+	src := `
+LOAD %A #100
+STR %A 0xFFE0
+LOAD %1 #1
+STR %1 0xFFE4
+LOAD %B 0xFFE2
+HALT
+`
+	sim := runSource(t, src, WithOutput(&bytes.Buffer{}), WithDevice(NewTimer()))
+
+	if c := sim.ReadReg(RegisterB); c != 100 {
+		t.Errorf("counter %d read by the next instruction, expected 100", c)
+	}
+}
+
+func TestTimerIRQ(t *testing.T) {
+	src := `
+    ORG 0xFFF8
+    FDB on_timer
+
+    ORG 0xFFFE
+    FDB main
+
+    ORG 0x0000
+:main
+    LDSP #0x00FF
+    LOAD %0 #3
+    LOAD %A #50
+    STR %A 0xFFE0
+    LOAD %1 #0x07
+    STR %1 0xFFE4
+    EI
+
+:idle
+    WAI
+    CMP %0 #0
+    BNZ idle
+    HALT
+
+:on_timer
+    STR %1 0xFFE5
+    LOAD %1 #"."
+    STR %1 0xFFFD
+    DEC %0
+    RTI
+`
+	var out bytes.Buffer
+	runSource(t, src, WithOutput(&bytes.Buffer{}), WithPrinterOutput(&out), WithDevice(NewTimer()))
+
+	if out.String() != "..." {
+		t.Errorf("printed %q, expected %q", out.String(), "...")
+	}
+}
@@ -2,9 +2,10 @@ package vm
 
 import (
 	"bytes"
-	"fmt"
+	"errors"
 	"io"
-	"os"
+	"io/ioutil"
+	"sync/atomic"
 
 	"github.com/lfkeitel/asml-sim/pkg/opcodes"
 	"github.com/lfkeitel/asml-sim/pkg/parser"
@@ -19,215 +20,552 @@ const (
 	regB = 0xB
 	regC = 0xC
 	regD = 0xD
+
+	// Stack pointer
+	regSP = opcodes.RegSP
 )
 
 type VM struct {
 	registers  []uint8
 	memory     []uint8
 	pc, sp     uint16
+	flags      uint8
+	cycles     uint64
 	output     bytes.Buffer
-	printer    bytes.Buffer
+	printer    *printer
+	printerOut io.Writer
+	legacy     bool
+	devices    []Device
+	out        io.Writer
 	printState bool
+	strict     bool
+	halted     bool
+	waiting    bool
+	nmi        int32 // Set by NMI, accessed atomically
+	running    bool
+	instPC     uint16
+	err        error
+	protected  []memRange
+	step       *StepInfo
+}
+
+// memRange is an inclusive range of memory addresses.
+type memRange struct {
+	start, end uint16
 }
 
-func New(code []parser.CodePart, printState bool) *VM {
+// Errors returned by New and Step
+var (
+	ErrNoCode       = errors.New("no code given")
+	ErrCodeOverflow = errors.New("code overflowed past address 0xFFFF")
+	ErrHalted       = errors.New("machine is halted")
+
+	ErrPrinterOptions = errors.New("the printer output can't be set with the legacy printer")
+)
+
+// New creates a machine with code loaded into memory and the program counter
+// set from the reset address.
+func New(code []parser.CodePart, opts ...Option) (*VM, error) {
 	if len(code) == 0 {
-		fmt.Println("No code given")
-		os.Exit(1)
+		return nil, ErrNoCode
 	}
 
 	newvm := &VM{
-		registers:  make([]uint8, numOfRegisters),
-		memory:     make([]uint8, numOfMemoryCells),
-		printState: printState,
+		registers: make([]uint8, numOfRegisters),
+		memory:    make([]uint8, numOfMemoryCells),
+		out:       ioutil.Discard,
+		printer:   &printer{},
+	}
+	newvm.devices = []Device{newvm.printer}
+
+	for _, opt := range opts {
+		opt(newvm)
+	}
+
+	if newvm.legacy && newvm.printerOut != nil {
+		return nil, ErrPrinterOptions
+	}
+	if !newvm.legacy {
+		newvm.printer.w = newvm.printerOut
+		if newvm.printer.w == nil {
+			newvm.printer.w = &newvm.output
+			newvm.printer.state = newvm.printState
+		}
 	}
 
 	for _, c := range code {
-		pc := c.StartPC
-
-		overflow := false
-		for i, b := range c.Bytes {
-			if overflow {
-				fmt.Println("Code overflowed past address 0xFFFF")
-				os.Exit(1)
-			}
-
-			loc := uint16(i) + pc
-			newvm.memory[loc] = b
-			if loc == numOfMemoryCells-1 {
-				overflow = true
-			}
+		if int(c.StartPC)+len(c.Bytes) > numOfMemoryCells {
+			return nil, ErrCodeOverflow
 		}
+		copy(newvm.memory[c.StartPC:], c.Bytes)
 	}
 
 	newvm.Reset()
 
-	return newvm
+	return newvm, nil
 }
 
 func (vm *VM) Reset() {
 	vm.pc = (uint16(vm.memory[0xFFFE]) << 8) | uint16(vm.memory[0xFFFF])
+	vm.halted = false
+	vm.waiting = false
+	atomic.StoreInt32(&vm.nmi, 0)
+	vm.err = nil
+}
+
+// Cycles returns the number of cycles executed since the machine started.
+// Most instructions take one cycle.
+func (vm *VM) Cycles() uint64 {
+	return vm.cycles
 }
 
 func (vm *VM) Output() []byte {
 	return vm.output.Bytes()
 }
 
-func (vm *VM) Run(out io.Writer) error {
-mainLoop:
-	for {
+// Run executes the program until it halts and writes the output to the
+// writer given with WithOutput. If the machine faults, the returned error
+// is a *Fault.
+func (vm *VM) Run() error {
+	for !vm.halted {
+		vm.Step()
+	}
+	return vm.err
+}
+
+// Step executes a single instruction and returns a description of it. If the
+// instruction faults, the returned error is a *Fault. Stepping a halted
+// machine returns ErrHalted, or the fault that halted it.
+func (vm *VM) Step() (*StepInfo, error) {
+	if vm.halted {
+		if vm.err != nil {
+			return nil, vm.err
+		}
+		return nil, ErrHalted
+	}
+
+	// The stack writes and vector read of an interrupt taken before the
+	// instruction are recorded along with the instruction's.
+	info := &StepInfo{}
+	flags := vm.flags
+	vm.instPC = vm.pc
+	vm.step = info
+	vm.runInstruction(func() { info.Interrupt = vm.interrupt() })
+	vm.step = nil
+	if vm.halted {
+		info.Addr = vm.instPC
+		info.Halted = true
+		info.setFlags(flags, vm.flags)
+		return info, vm.err
+	}
+
+	if vm.waiting {
+		// A pending IRQ wakes the machine even if interrupts are disabled,
+		// execution then continues after the WAI.
+		if !vm.irqPending() {
+			vm.cycles++
+			vm.tickDevices(1)
+			return &StepInfo{Addr: vm.pc, Cycles: 1, Waiting: true, Flags: vm.flags}, nil
+		}
+		vm.waiting = false
+	}
+
+	info.Addr = vm.pc
+	info.Cycles = vm.cycles
+	vm.instPC = vm.pc
+	vm.cycles++
+	vm.runInstruction(func() {
 		opcode := vm.fetchByte()
+		info.Opcode = opcode
+		info.Mnemonic, _ = opcodes.Name(opcode)
+		vm.step = info
 
 		if vm.printState {
 			vm.PrintState()
-			fmt.Println(vm.output.String())
-			vm.output.Reset()
+			vm.output.WriteByte('\n')
+			vm.flushOutput()
 		}
-
-		switch opcode {
-		case opcodes.NOOP:
-			// noop
-
-		case opcodes.LOADI:
-			vm.writeStateMessage("Instr: LOADI\n")
-			vm.loadIntoReg(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.LOADA:
-			vm.writeStateMessage("Instr: LOADA\n")
-			vm.loadFromMem(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.LOADR:
-			vm.writeStateMessage("Instr: LOADR\n")
-			vm.loadRegInMemoryAddr(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.STRA:
-			vm.writeStateMessage("Instr: STRA\n")
-			vm.storeRegToMemory(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.STRR:
-			vm.writeStateMessage("Instr: STRR\n")
-			vm.storeRegToRegAddr(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.XFER:
-			vm.writeStateMessage("Instr: XFER\n")
-			vm.xferRegisters(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.ADDA:
-			vm.writeStateMessage("Instr: ADDA\n")
-			vm.addAddr(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ADDI:
-			vm.writeStateMessage("Instr: ADDI\n")
-			vm.addImm(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ADDR:
-			vm.writeStateMessage("Instr: ADDR\n")
-			vm.addReg(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.ORA:
-			vm.writeStateMessage("Instr: ORA\n")
-			vm.orAddr(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ORI:
-			vm.writeStateMessage("Instr: ORI\n")
-			vm.orImm(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ORR:
-			vm.writeStateMessage("Instr: ORR\n")
-			vm.orReg(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.ANDA:
-			vm.writeStateMessage("Instr: ANDA\n")
-			vm.andAddr(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ANDI:
-			vm.writeStateMessage("Instr: ANDI\n")
-			vm.andImm(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.ANDR:
-			vm.writeStateMessage("Instr: ANDR\n")
-			vm.andReg(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.XORA:
-			vm.writeStateMessage("Instr: XORA\n")
-			vm.xorAddr(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.XORI:
-			vm.writeStateMessage("Instr: XORI\n")
-			vm.xorImm(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.XORR:
-			vm.writeStateMessage("Instr: XORR\n")
-			vm.xorReg(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.ROTR:
-			vm.writeStateMessage("Instr: ROTR\n")
-			vm.rotrRegister(vm.fetchByte(), vm.fetchByte())
-		case opcodes.ROTL:
-			vm.writeStateMessage("Instr: ROTL\n")
-			vm.rotlRegister(vm.fetchByte(), vm.fetchByte())
-
-		case opcodes.JMP:
-			vm.writeStateMessage("Instr: JMP\n")
-			vm.jumpEq(vm.fetchByte(), vm.fetchUint16())
-		case opcodes.JMPA:
-			vm.writeStateMessage("Instr: JMPA\n")
-			vm.jumpAbs(vm.fetchUint16())
-
-		case opcodes.HALT:
-			vm.writeStateMessage("Instr: HALT\n")
-			if vm.printState {
-				vm.writeString("\nPrinter: ")
-			}
-			vm.writePrinter()
-			vm.writeString("\n")
-			break mainLoop
-
-		case opcodes.LDSPA:
-			vm.writeStateMessage("Instr: LDSPA\n")
-			vm.loadSPAddr(vm.fetchUint16())
-		case opcodes.LDSPI:
-			vm.writeStateMessage("Instr: LDSPI\n")
-			vm.loadSPImm(vm.fetchUint16())
-		case opcodes.LDSPR:
-			vm.writeStateMessage("Instr: LDSPR\n")
-			vm.loadSPReg(vm.fetchByte())
-
-		case opcodes.PUSH:
-			vm.writeStateMessage("Instr: PUSH\n")
-			vm.push(vm.fetchByte())
-		case opcodes.POP:
-			vm.writeStateMessage("Instr: POP\n")
-			vm.pop(vm.fetchByte())
-
-		case opcodes.CALLA:
-			vm.writeStateMessage("Instr: CALLA\n")
-			vm.calla(vm.fetchUint16())
-		case opcodes.CALLR:
-			vm.writeStateMessage("Instr: CALLR\n")
-			vm.callr(vm.fetchByte())
-
-		case opcodes.RTN:
-			vm.writeStateMessage("Instr: RTN\n")
-			vm.rtn()
-
-		default:
-			vm.writeString("INVALID OPCODE\n")
-			if vm.printState {
-				vm.writeString("\nPrinter: ")
-			}
-			vm.writePrinter()
-			vm.writeString("\n")
-			break mainLoop
+		if info.Mnemonic != "" && opcode != opcodes.NOOP {
+			vm.writeStateMessage("Instr: " + info.Mnemonic + "\n")
 		}
 
-		// Print character in memory address FF and reset it to 0
-		if vm.memory[numOfMemoryCells-3] > 0 {
-			vm.printer.WriteByte(byte(vm.memory[numOfMemoryCells-3]))
-			vm.memory[numOfMemoryCells-3] = 0
-		}
+		vm.execute(opcode)
+	})
+
+	vm.step = nil
+	vm.flushOutput()
+
+	info.Cycles = vm.cycles - info.Cycles
+	vm.tickDevices(info.Cycles)
+	info.setFlags(flags, vm.flags)
+	info.Halted = vm.halted
+	return info, vm.err
+}
+
+// execute runs the instruction for opcode. The opcode has already been
+// fetched, operands are fetched as they're needed.
+func (vm *VM) execute(opcode byte) {
+	switch opcode {
+	case opcodes.NOOP:
+		// noop
+
+	case opcodes.LOADI:
+		vm.loadIntoReg(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.LOADA:
+		vm.loadFromMem(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.LOADR:
+		vm.loadRegInMemoryAddr(vm.fetchByte(), vm.fetchByte())
+	case opcodes.LOADX:
+		vm.loadIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+	case opcodes.LOADP:
+		vm.loadIndirect(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.LOADSP:
+		vm.loadStackRel(vm.fetchByte(), vm.fetchUint16())
+
+	case opcodes.LOADSA:
+		vm.loadSignExt(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.LOADSR:
+		vm.loadSignExtRegAddr(vm.fetchByte(), vm.fetchByte())
+	case opcodes.LOADZA:
+		vm.loadZeroExt(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.LOADZR:
+		vm.loadZeroExtRegAddr(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.STRA:
+		vm.storeRegToMemory(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.STRR:
+		vm.storeRegToRegAddr(vm.fetchByte(), vm.fetchByte())
+	case opcodes.STRX:
+		vm.storeIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+	case opcodes.STRP:
+		vm.storeIndirect(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.STRSP:
+		vm.storeStackRel(vm.fetchByte(), vm.fetchUint16())
+
+	case opcodes.XFER:
+		vm.xferRegisters(vm.fetchByte(), vm.fetchByte())
+	case opcodes.XFERS:
+		vm.xferSignExt(vm.fetchByte(), vm.fetchByte())
+	case opcodes.XFERZ:
+		vm.xferZeroExt(vm.fetchByte(), vm.fetchByte())
+	case opcodes.TRUNC:
+		vm.truncate(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.MEMCPY:
+		vm.memcpy(vm.fetchByte(), vm.fetchByte(), vm.fetchByte())
+	case opcodes.MEMSET:
+		vm.memset(vm.fetchByte(), vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.ADDA:
+		vm.addAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ADDI:
+		vm.addImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ADDR:
+		vm.addReg(vm.fetchByte(), vm.fetchByte())
+	case opcodes.ADDX:
+		vm.addIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+
+	case opcodes.ADCA:
+		vm.adcAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ADCI:
+		vm.adcImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ADCR:
+		vm.adcReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.SUBA:
+		vm.subAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.SUBI:
+		vm.subImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.SUBR:
+		vm.subReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.SBCA:
+		vm.sbcAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.SBCI:
+		vm.sbcImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.SBCR:
+		vm.sbcReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.CMPA:
+		vm.cmpAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.CMPI:
+		vm.cmpImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.CMPR:
+		vm.cmpReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.NEGA:
+		vm.negAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.NEGI:
+		vm.negImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.NEGR:
+		vm.negReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.INC:
+		vm.inc(vm.fetchByte())
+	case opcodes.DEC:
+		vm.dec(vm.fetchByte())
+
+	case opcodes.MULA:
+		vm.mulAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.MULI:
+		vm.mulImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.MULR:
+		vm.mulReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.DIVA:
+		vm.divAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.DIVI:
+		vm.divImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.DIVR:
+		vm.divReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.MODA:
+		vm.modAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.MODI:
+		vm.modImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.MODR:
+		vm.modReg(vm.fetchByte(), vm.fetchByte())
+	case opcodes.MULW:
+		vm.mulWide(vm.fetchByte(), vm.fetchByte(), vm.fetchByte())
+	case opcodes.DIVW:
+		vm.divideWide(vm.fetchByte(), vm.fetchByte(), vm.fetchByte(), false)
+	case opcodes.MODW:
+		vm.divideWide(vm.fetchByte(), vm.fetchByte(), vm.fetchByte(), true)
+
+	case opcodes.ORA:
+		vm.orAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ORI:
+		vm.orImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ORR:
+		vm.orReg(vm.fetchByte(), vm.fetchByte())
+	case opcodes.ORX:
+		vm.orIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+
+	case opcodes.ANDA:
+		vm.andAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ANDI:
+		vm.andImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.ANDR:
+		vm.andReg(vm.fetchByte(), vm.fetchByte())
+	case opcodes.ANDX:
+		vm.andIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+
+	case opcodes.XORA:
+		vm.xorAddr(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.XORI:
+		vm.xorImm(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.XORR:
+		vm.xorReg(vm.fetchByte(), vm.fetchByte())
+	case opcodes.XORX:
+		vm.xorIndexed(vm.fetchByte(), vm.fetchUint16(), vm.fetchByte())
+
+	case opcodes.ROTR:
+		vm.rotrRegister(vm.fetchByte(), vm.fetchByte())
+	case opcodes.ROTL:
+		vm.rotlRegister(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.SHLI:
+		vm.shlImm(vm.fetchByte(), vm.fetchByte())
+	case opcodes.SHLR:
+		vm.shlReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.SHRI:
+		vm.shrImm(vm.fetchByte(), vm.fetchByte())
+	case opcodes.SHRR:
+		vm.shrReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.ASRI:
+		vm.asrImm(vm.fetchByte(), vm.fetchByte())
+	case opcodes.ASRR:
+		vm.asrReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.BSETA:
+		vm.bsetAddr(vm.fetchUint16(), vm.fetchByte())
+	case opcodes.BSETR:
+		vm.bsetReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.BCLRA:
+		vm.bclrAddr(vm.fetchUint16(), vm.fetchByte())
+	case opcodes.BCLRR:
+		vm.bclrReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.BTSTA:
+		vm.btstAddr(vm.fetchUint16(), vm.fetchByte())
+	case opcodes.BTSTR:
+		vm.btstReg(vm.fetchByte(), vm.fetchByte())
+
+	case opcodes.JMP:
+		vm.jumpEq(vm.fetchByte(), vm.fetchUint16())
+	case opcodes.JMPA:
+		vm.jumpAbs(vm.fetchUint16())
+	case opcodes.JMPP:
+		vm.jumpIndirect(vm.fetchUint16())
+	case opcodes.LOOP:
+		vm.loop(vm.fetchByte(), vm.fetchUint16())
+
+	case opcodes.JZ:
+		vm.jumpFlag(FlagZero, true, vm.fetchUint16())
+	case opcodes.JNZ:
+		vm.jumpFlag(FlagZero, false, vm.fetchUint16())
+	case opcodes.JC:
+		vm.jumpFlag(FlagCarry, true, vm.fetchUint16())
+	case opcodes.JNC:
+		vm.jumpFlag(FlagCarry, false, vm.fetchUint16())
+	case opcodes.JN:
+		vm.jumpFlag(FlagNegative, true, vm.fetchUint16())
+	case opcodes.JNN:
+		vm.jumpFlag(FlagNegative, false, vm.fetchUint16())
+	case opcodes.JV:
+		vm.jumpFlag(FlagOverflow, true, vm.fetchUint16())
+	case opcodes.JNV:
+		vm.jumpFlag(FlagOverflow, false, vm.fetchUint16())
+
+	case opcodes.BRA:
+		vm.branch(vm.fetchDisp8())
+	case opcodes.BSR:
+		vm.branchSub(vm.fetchDisp8())
+	case opcodes.LBRA:
+		vm.branch(vm.fetchUint16())
+	case opcodes.LBSR:
+		vm.branchSub(vm.fetchUint16())
+
+	case opcodes.BZ:
+		vm.branchFlag(FlagZero, true, vm.fetchDisp8())
+	case opcodes.BNZ:
+		vm.branchFlag(FlagZero, false, vm.fetchDisp8())
+	case opcodes.BC:
+		vm.branchFlag(FlagCarry, true, vm.fetchDisp8())
+	case opcodes.BNC:
+		vm.branchFlag(FlagCarry, false, vm.fetchDisp8())
+	case opcodes.BN:
+		vm.branchFlag(FlagNegative, true, vm.fetchDisp8())
+	case opcodes.BNN:
+		vm.branchFlag(FlagNegative, false, vm.fetchDisp8())
+	case opcodes.BV:
+		vm.branchFlag(FlagOverflow, true, vm.fetchDisp8())
+	case opcodes.BNV:
+		vm.branchFlag(FlagOverflow, false, vm.fetchDisp8())
+
+	case opcodes.HALT:
+		vm.halt()
+
+	case opcodes.EI:
+		vm.setFlag(FlagInterrupt, true)
+	case opcodes.DI:
+		vm.setFlag(FlagInterrupt, false)
+	case opcodes.RTI:
+		vm.rti()
+	case opcodes.WAI:
+		vm.waiting = true
+
+	case opcodes.LDSPA:
+		vm.loadSPAddr(vm.fetchUint16())
+	case opcodes.LDSPI:
+		vm.loadSPImm(vm.fetchUint16())
+	case opcodes.LDSPR:
+		vm.loadSPReg(vm.fetchByte())
+
+	case opcodes.PUSH:
+		vm.push(vm.fetchByte())
+	case opcodes.POP:
+		vm.pop(vm.fetchByte())
+	case opcodes.PUSHA:
+		vm.pushAll()
+	case opcodes.POPA:
+		vm.popAll()
+	case opcodes.PUSHF:
+		vm.push8(vm.flags)
+	case opcodes.POPF:
+		vm.flags = vm.pop8()
+
+	case opcodes.CALLA:
+		vm.calla(vm.fetchUint16())
+	case opcodes.CALLR:
+		vm.callr(vm.fetchByte())
+	case opcodes.CALLP:
+		vm.callIndirect(vm.fetchUint16())
+	case opcodes.CALLEQ:
+		vm.callEq(vm.fetchByte(), vm.fetchUint16())
+
+	case opcodes.CALLZ:
+		vm.callFlag(FlagZero, true, vm.fetchUint16())
+	case opcodes.CALLNZ:
+		vm.callFlag(FlagZero, false, vm.fetchUint16())
+	case opcodes.CALLC:
+		vm.callFlag(FlagCarry, true, vm.fetchUint16())
+	case opcodes.CALLNC:
+		vm.callFlag(FlagCarry, false, vm.fetchUint16())
+	case opcodes.CALLN:
+		vm.callFlag(FlagNegative, true, vm.fetchUint16())
+	case opcodes.CALLNN:
+		vm.callFlag(FlagNegative, false, vm.fetchUint16())
+	case opcodes.CALLV:
+		vm.callFlag(FlagOverflow, true, vm.fetchUint16())
+	case opcodes.CALLNV:
+		vm.callFlag(FlagOverflow, false, vm.fetchUint16())
+
+	case opcodes.RTN:
+		vm.rtn()
+
+	case opcodes.RTNZ:
+		vm.rtnFlag(FlagZero, true)
+	case opcodes.RTNNZ:
+		vm.rtnFlag(FlagZero, false)
+	case opcodes.RTNC:
+		vm.rtnFlag(FlagCarry, true)
+	case opcodes.RTNNC:
+		vm.rtnFlag(FlagCarry, false)
+	case opcodes.RTNN:
+		vm.rtnFlag(FlagNegative, true)
+	case opcodes.RTNNN:
+		vm.rtnFlag(FlagNegative, false)
+	case opcodes.RTNV:
+		vm.rtnFlag(FlagOverflow, true)
+	case opcodes.RTNNV:
+		vm.rtnFlag(FlagOverflow, false)
+
+	default:
+		vm.fault(FaultInvalidOpcode)
 	}
+}
 
-	out.Write(vm.output.Bytes())
+// flushOutput writes the buffered output to the output writer.
+func (vm *VM) flushOutput() {
+	vm.out.Write(vm.output.Bytes())
+	vm.output.Reset()
+}
 
-	return nil
+// halt stops execution. In legacy printer mode the printed characters are
+// written to the output.
+func (vm *VM) halt() {
+	if vm.legacy {
+		if vm.printState {
+			vm.writeString("\nPrinter: ")
+		}
+		vm.writePrinter()
+	}
+	vm.writeString("\n")
+	vm.closeDevices()
+	vm.halted = true
 }
 
 func (vm *VM) fetchByte() byte {
 	b1 := vm.memory[vm.pc]
+	if vm.step != nil {
+		vm.step.Operands = append(vm.step.Operands, b1)
+	}
 	vm.pc++
+	if vm.pc == 0 {
+		vm.fault(FaultPCWraparound)
+	}
 	return b1
 }
 
+// fetchDisp8 fetches a signed 8-bit displacement and sign extends it.
+func (vm *VM) fetchDisp8() uint16 {
+	return uint16(int8(vm.fetchByte()))
+}
+
 func (vm *VM) fetchUint16() uint16 {
 	b1 := uint16(vm.fetchByte())
 	b2 := uint16(vm.fetchByte())
@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lfkeitel/asml-sim/pkg/lexer"
+	"github.com/lfkeitel/asml-sim/pkg/linker"
+	"github.com/lfkeitel/asml-sim/pkg/parser"
+)
+
+// maxTestSteps stops a test program that doesn't halt.
+const maxTestSteps = 100000
+
+// newTestVM assembles src and loads it into a new machine.
+func newTestVM(t *testing.T, src string, opts ...Option) *VM {
+	t.Helper()
+
+	program, err := parser.New(lexer.NewString(src)).Parse()
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	if err := linker.Link(program); err != nil {
+		t.Fatalf("linking failed: %v", err)
+	}
+
+	sim, err := New(program.Parts, opts...)
+	if err != nil {
+		t.Fatalf("creating machine failed: %v", err)
+	}
+	return sim
+}
+
+// runTestVM runs a machine until it halts and returns the error from the
+// last step.
+func runTestVM(t *testing.T, sim *VM) error {
+	t.Helper()
+
+	for i := 0; i < maxTestSteps; i++ {
+		info, err := sim.Step()
+		if err != nil || info.Halted {
+			return err
+		}
+	}
+	t.Fatalf("program didn't halt after %d steps", maxTestSteps)
+	return nil
+}
+
+// runSource assembles and runs src and fails the test if the machine faults.
+func runSource(t *testing.T, src string, opts ...Option) *VM {
+	t.Helper()
+
+	sim := newTestVM(t, src, opts...)
+	if err := runTestVM(t, sim); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+	return sim
+}
+
+func TestPrinterOptions(t *testing.T) {
+	code := []parser.CodePart{{Bytes: []byte{0x00}}}
+
+	_, err := New(code, WithPrinterOutput(&bytes.Buffer{}), WithLegacyPrinter(true))
+	if err != ErrPrinterOptions {
+		t.Errorf("printer output with the legacy printer returned %v, expected ErrPrinterOptions", err)
+	}
+
+	if _, err := New(code, WithPrinterOutput(&bytes.Buffer{}), WithLegacyPrinter(false)); err != nil {
+		t.Errorf("printer output without the legacy printer returned %v", err)
+	}
+}